@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"os"
-	"sync"
+	"time"
 
+	"github.com/andreyst/go-sqs/internal/auth"
+	"github.com/andreyst/go-sqs/internal/config"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
 	"github.com/andreyst/go-sqs/internal/server"
 	uuid "github.com/satori/go.uuid"
 
@@ -14,58 +19,138 @@ import (
 )
 
 // Queues TODO: add comment
-var queues sync.Map
+var queues = queuemgr.New()
+
+var serverConfig = config.Default()
 
 func handler(w http.ResponseWriter, r *http.Request) {
 	// TODO: Validate it is a POST request
 	// TODO: Handle also GET parameters
 	// TODO: Handle headers passed as GET parameters
-	r.ParseForm()
-
 	var req = server.Request{
-		ID:     uuid.Must(uuid.NewV4()).String(),
-		Params: r.Form,
+		ID:          uuid.Must(uuid.NewV4()).String(),
+		Protocol:    server.ProtocolOf(r),
+		AccountID:   serverConfig.AccountForAccessKey(auth.AccessKeyID(r)),
+		Region:      serverConfig.RegionOf(auth.Region(r)),
+		BaseURL:     serverConfig.BaseURL(r),
+		TraceHeader: r.Header.Get("X-Amzn-Trace-Id"),
+		Context:     r.Context(),
+	}
+
+	if serverConfig.VerifySignatures {
+		var Body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(Body))
+
+		if ok, ErrorCode, ErrorMessage := auth.VerifySignature(r, Body, serverConfig.Credentials, time.Now()); !ok {
+			var resp = server.Response{
+				Req:    req,
+				Header: w.Header(),
+			}
+			var ResponseBody, StatusCode = resp.ErrorWithStatus(ErrorCode, ErrorMessage, 403)
+			w.WriteHeader(StatusCode)
+			fmt.Fprint(w, ResponseBody)
+			return
+		}
+	}
+
+	var Action string
+	var ParseError error
+	if req.Protocol == server.JSONProtocol {
+		Action, req.Params, ParseError = server.ParseJSONRequest(r)
+	} else {
+		r.ParseForm()
+		req.Params = r.Form
+		Action = r.Form.Get("Action")
 	}
+
 	var resp = server.Response{
-		Req: req,
+		Req:    req,
+		Header: w.Header(),
+	}
+
+	if ParseError != nil {
+		var ResponseBody, StatusCode = resp.Error("SerializationException", fmt.Sprintf("Unable to parse request body: %s", ParseError))
+		w.WriteHeader(StatusCode)
+		fmt.Fprint(w, ResponseBody)
+		return
+	}
+
+	if serverConfig.EnforcePolicies && !handlers.Authorize(req, auth.NewAccessRequest(r, req.AccountID, Action), queues, serverConfig) {
+		var ResponseBody, StatusCode = resp.ErrorWithStatus("AccessDenied", "Access to the resource is denied.", 403)
+		w.WriteHeader(StatusCode)
+		fmt.Fprint(w, ResponseBody)
+		return
 	}
 
-	var Action = r.Form.Get("Action")
 	var ResponseBody = ""
 	var StatusCode = 0
 	switch Action {
+	case "AddPermission":
+		ResponseBody, StatusCode = handlers.AddPermission(req, resp, queues)
+	case "ChangeMessageVisibility":
+		ResponseBody, StatusCode = handlers.ChangeMessageVisibility(req, resp, queues)
+	case "ChangeMessageVisibilityBatch":
+		ResponseBody, StatusCode = handlers.ChangeMessageVisibilityBatch(req, resp, queues)
 	case "CreateQueue":
-		ResponseBody, StatusCode = handlers.CreateQueue(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.CreateQueue(req, resp, queues)
 	case "DeleteMessage":
-		ResponseBody, StatusCode = handlers.DeleteMessage(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.DeleteMessage(req, resp, queues)
 	case "DeleteMessageBatch":
-		ResponseBody, StatusCode = handlers.DeleteMessageBatch(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.DeleteMessageBatch(req, resp, queues)
 	case "DeleteQueue":
-		ResponseBody, StatusCode = handlers.DeleteQueue(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.DeleteQueue(req, resp, queues)
 	case "GetQueueAttributes":
-		ResponseBody, StatusCode = handlers.GetQueueAttributes(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.GetQueueAttributes(req, resp, queues)
 	case "GetQueueUrl":
-		ResponseBody, StatusCode = handlers.GetQueueURL(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.GetQueueURL(req, resp, queues)
+	case "ListDeadLetterSourceQueues":
+		ResponseBody, StatusCode = handlers.ListDeadLetterSourceQueues(req, resp, queues)
 	case "ListQueues":
-		ResponseBody, StatusCode = handlers.ListQueues(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.ListQueues(req, resp, queues)
+	case "ListQueueTags":
+		ResponseBody, StatusCode = handlers.ListQueueTags(req, resp, queues)
+	case "PurgeQueue":
+		ResponseBody, StatusCode = handlers.PurgeQueue(req, resp, queues)
+	case "SetQueueAttributes":
+		ResponseBody, StatusCode = handlers.SetQueueAttributes(req, resp, queues)
 	case "SendMessage":
-		ResponseBody, StatusCode = handlers.SendMessage(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.SendMessage(req, resp, queues)
 	case "SendMessageBatch":
-		ResponseBody, StatusCode = handlers.SendMessageBatch(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.SendMessageBatch(req, resp, queues)
 	case "ReceiveMessage":
-		ResponseBody, StatusCode = handlers.ReceiveMessage(req, resp, &queues)
+		ResponseBody, StatusCode = handlers.ReceiveMessage(req, resp, queues)
+	case "RemovePermission":
+		ResponseBody, StatusCode = handlers.RemovePermission(req, resp, queues)
+	case "TagQueue":
+		ResponseBody, StatusCode = handlers.TagQueue(req, resp, queues)
+	case "UntagQueue":
+		ResponseBody, StatusCode = handlers.UntagQueue(req, resp, queues)
 	default:
 		ResponseBody, StatusCode = resp.Error("InvalidAction", "The action or operation requested is invalid. Verify that the action is typed correctly.")
 	}
 
 	w.WriteHeader(StatusCode)
-	fmt.Fprintf(w, ResponseBody)
+	fmt.Fprint(w, ResponseBody)
 }
 
 func main() {
+	var ConfigPath = flag.String("config", "", "path to a JSON configuration file")
+	flag.Parse()
+
 	var Port = "8080"
-	if len(os.Args) > 1 {
-		Port = os.Args[1]
+	if flag.NArg() > 0 {
+		Port = flag.Arg(0)
+	}
+
+	if *ConfigPath != "" {
+		var err error
+		if serverConfig, err = config.Load(*ConfigPath); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	http.HandleFunc("/", handler)
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var credentialPattern = regexp.MustCompile(`Credential=([^,\s]+)`)
+
+// AccessKeyID returns an access key ID a request was signed with, either in Authorization header
+// or in query string of a presigned request. It returns an empty string for unsigned requests.
+func AccessKeyID(r *http.Request) string {
+	return credential(r)[0]
+}
+
+// Region returns a region from credential scope of a signed request.
+// It returns an empty string for unsigned requests.
+func Region(r *http.Request) string {
+	var Credential = credential(r)
+	if len(Credential) < 3 {
+		return ""
+	}
+
+	return Credential[2]
+}
+
+// credential returns parts of a credential of a request: access key ID, date, region, service and terminator.
+func credential(r *http.Request) []string {
+	var Match = credentialPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if Match != nil {
+		return strings.Split(Match[1], "/")
+	}
+
+	return strings.Split(r.URL.Query().Get("X-Amz-Credential"), "/")
+}
@@ -0,0 +1,173 @@
+// Package auth identifies callers and decides whether they are allowed to access queues.
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+// Decision represents a result of policy evaluation.
+type Decision int
+
+const (
+	// NotApplicable means that no statement matched the request.
+	NotApplicable Decision = iota
+	// Allow means that a statement allowed the request and none denied it.
+	Allow
+	// Deny means that a statement explicitly denied the request.
+	Deny
+)
+
+// AccessRequest represents a request which is checked against policies.
+// Action is an SQS action name, e.g. SendMessage, Resource is a queue ARN.
+// Context holds values of condition keys, e.g. aws:SourceArn.
+type AccessRequest struct {
+	AccountID string
+	Action    string
+	Resource  string
+	Context   map[string]string
+}
+
+// batchActions maps batch actions to actions which grant them in policies.
+var batchActions = map[string]string{
+	"ChangeMessageVisibilityBatch": "ChangeMessageVisibility",
+	"DeleteMessageBatch":           "DeleteMessage",
+	"SendMessageBatch":             "SendMessage",
+}
+
+// NewAccessRequest builds an access request of a caller from given account, Resource is to be filled
+// by the caller. Condition keys aws:SourceArn and aws:SourceAccount are taken from X-Amz-Source-Arn
+// and X-Amz-Source-Account headers, so that requests made by AWS services on behalf of other resources
+// could be emulated.
+func NewAccessRequest(r *http.Request, AccountID string, Action string) AccessRequest {
+	var Context = map[string]string{
+		"aws:PrincipalAccount": AccountID,
+		"aws:PrincipalArn":     PrincipalArn(AccountID),
+	}
+	if SourceArn := r.Header.Get("X-Amz-Source-Arn"); SourceArn != "" {
+		Context["aws:SourceArn"] = SourceArn
+	}
+	if SourceAccount := r.Header.Get("X-Amz-Source-Account"); SourceAccount != "" {
+		Context["aws:SourceAccount"] = SourceAccount
+	}
+
+	if BatchAction, ok := batchActions[Action]; ok {
+		Action = BatchAction
+	}
+
+	return AccessRequest{
+		AccountID: AccountID,
+		Action:    Action,
+		Context:   Context,
+	}
+}
+
+// PrincipalArn returns an ARN of the root principal of an account.
+func PrincipalArn(AccountID string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:root", AccountID)
+}
+
+// EvaluatePolicies returns Deny if any statement of the policies denies the request,
+// Allow if any statement allows it and NotApplicable otherwise.
+func EvaluatePolicies(Policies []*queue.Policy, Request AccessRequest) Decision {
+	var Result = NotApplicable
+	for _, Policy := range Policies {
+		if Policy == nil {
+			continue
+		}
+		for _, Statement := range Policy.Statement {
+			if !matchStatement(Statement, Request) {
+				continue
+			}
+			if Statement.Effect == "Deny" {
+				return Deny
+			}
+			Result = Allow
+		}
+	}
+
+	return Result
+}
+
+func matchStatement(Statement queue.PolicyStatement, Request AccessRequest) bool {
+	if !matchPrincipal(Statement.Principal, Request.AccountID) {
+		return false
+	}
+	if !matchAny(Statement.Action, "sqs:"+Request.Action, true) {
+		return false
+	}
+	if len(Statement.Resource) > 0 && !matchAny(Statement.Resource, Request.Resource, false) {
+		return false
+	}
+
+	for Operator, Conditions := range Statement.Condition {
+		for Key, Values := range Conditions {
+			var Value, ok = Request.Context[Key]
+			if !matchCondition(Operator, Values, Value, ok) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// matchPrincipal checks whether a principal belongs to an account. Only AWS principals are supported,
+// they could be specified either as an account ID or as an ARN of any principal in the account.
+func matchPrincipal(Principal queue.PolicyPrincipal, AccountID string) bool {
+	for _, Value := range Principal["AWS"] {
+		if Value == "*" || Value == AccountID || strings.HasPrefix(Value, fmt.Sprintf("arn:aws:iam::%s:", AccountID)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchCondition evaluates a single condition, negated operators match a missing key as AWS does.
+func matchCondition(Operator string, Values queue.PolicyValues, Value string, Present bool) bool {
+	switch Operator {
+	case "StringEquals", "ArnEquals":
+		return Present && containsString(Values, Value)
+	case "StringNotEquals", "ArnNotEquals":
+		return !Present || !containsString(Values, Value)
+	case "StringEqualsIgnoreCase":
+		return Present && matchAny(Values, Value, true)
+	case "StringLike", "ArnLike":
+		return Present && matchAny(Values, Value, false)
+	case "StringNotLike", "ArnNotLike":
+		return !Present || !matchAny(Values, Value, false)
+	}
+
+	// Unsupported operators never match
+	return false
+}
+
+func containsString(Values queue.PolicyValues, Value string) bool {
+	for _, Candidate := range Values {
+		if Candidate == Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny checks whether a value matches any of patterns with * and ? wildcards.
+func matchAny(Patterns queue.PolicyValues, Value string, IgnoreCase bool) bool {
+	for _, Pattern := range Patterns {
+		var Expression = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(Pattern))
+		if IgnoreCase {
+			Expression = "(?i)" + Expression
+		}
+		if regexp.MustCompile("^" + Expression + "$").MatchString(Value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/limits"
+)
+
+const (
+	signatureAlgorithm  = "AWS4-HMAC-SHA256"
+	signatureTimeFormat = "20060102T150405Z"
+	unsignedPayload     = "UNSIGNED-PAYLOAD"
+)
+
+// signature represents parts of a SigV4 signature taken either from Authorization header
+// or from query string of a presigned request.
+type signature struct {
+	AccessKeyID   string
+	Scope         string
+	Date          string
+	SignedHeaders []string
+	Signature     string
+	Presigned     bool
+	Expires       int
+}
+
+// VerifySignature checks a SigV4 signature of a request, Credentials maps access key IDs to secret keys.
+// Body is a body of the request, which is expected to be read beforehand.
+func VerifySignature(r *http.Request, Body []byte, Credentials map[string]string, Now time.Time) (bool, string, string) {
+	var Signature, ok, ErrorCode, ErrorMessage = parseSignature(r)
+	if !ok {
+		return false, ErrorCode, ErrorMessage
+	}
+
+	var SecretKey, KnownAccessKey = Credentials[Signature.AccessKeyID]
+	if !KnownAccessKey {
+		return false, "InvalidClientTokenId", "The security token included in the request is invalid."
+	}
+
+	var SignedAt, err = time.Parse(signatureTimeFormat, Signature.Date)
+	if err != nil {
+		return false, "IncompleteSignature", fmt.Sprintf("Date must be in ISO-8601 'basic format'. Got '%s'.", Signature.Date)
+	}
+	if Signature.Presigned {
+		if Now.After(SignedAt.Add(time.Duration(Signature.Expires) * time.Second)) {
+			return false, "RequestExpired", fmt.Sprintf("Request has expired. X-Amz-Date is %s, X-Amz-Expires is %d.", Signature.Date, Signature.Expires)
+		}
+		if SignedAt.Sub(Now) > limits.MaxClockSkew {
+			return false, "RequestExpired", fmt.Sprintf("Signature not yet current: %s is still later than %s.", Signature.Date, Now.Add(limits.MaxClockSkew).Format(signatureTimeFormat))
+		}
+	} else if Now.Sub(SignedAt) > limits.MaxClockSkew || SignedAt.Sub(Now) > limits.MaxClockSkew {
+		return false, "RequestExpired", fmt.Sprintf("Signature expired: %s is now outside of %s clock skew from %s.", Signature.Date, limits.MaxClockSkew, Now.Format(signatureTimeFormat))
+	}
+
+	var ScopeParts = strings.Split(Signature.Scope, "/")
+	if len(ScopeParts) != 4 || ScopeParts[3] != "aws4_request" || !strings.HasPrefix(Signature.Date, ScopeParts[0]) {
+		return false, "SignatureDoesNotMatch", fmt.Sprintf("Credential should be scoped to a valid date, region and service, got %s.", Signature.Scope)
+	}
+
+	// Payload hash header is signed, but it still has to match the body which it stands for
+	if PayloadHash := r.Header.Get("X-Amz-Content-Sha256"); PayloadHash != "" && PayloadHash != unsignedPayload && PayloadHash != hashHex(Body) {
+		return false, "SignatureDoesNotMatch", "The provided 'x-amz-content-sha256' header does not match what was computed."
+	}
+
+	var StringToSign = strings.Join([]string{
+		signatureAlgorithm,
+		Signature.Date,
+		Signature.Scope,
+		hashHex([]byte(canonicalRequest(r, Body, Signature))),
+	}, "\n")
+
+	var Key = []byte("AWS4" + SecretKey)
+	for _, ScopePart := range ScopeParts {
+		Key = hmacSHA256(Key, ScopePart)
+	}
+	var ExpectedSignature = hex.EncodeToString(hmacSHA256(Key, StringToSign))
+	if !hmac.Equal([]byte(ExpectedSignature), []byte(Signature.Signature)) {
+		return false, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your AWS Secret Access Key and signing method. Consult the service documentation for details."
+	}
+
+	return true, "", ""
+}
+
+func parseSignature(r *http.Request) (signature, bool, string, string) {
+	var Signature signature
+
+	var Authorization = r.Header.Get("Authorization")
+	if Authorization != "" {
+		if !strings.HasPrefix(Authorization, signatureAlgorithm+" ") {
+			return Signature, false, "IncompleteSignature", "Unsupported AWS signature algorithm, only AWS4-HMAC-SHA256 is supported."
+		}
+		for _, Part := range strings.Split(strings.TrimPrefix(Authorization, signatureAlgorithm+" "), ",") {
+			var KeyValue = strings.SplitN(strings.TrimSpace(Part), "=", 2)
+			if len(KeyValue) != 2 {
+				continue
+			}
+			switch KeyValue[0] {
+			case "Credential":
+				Signature.AccessKeyID, Signature.Scope = splitCredential(KeyValue[1])
+			case "SignedHeaders":
+				Signature.SignedHeaders = strings.Split(KeyValue[1], ";")
+			case "Signature":
+				Signature.Signature = KeyValue[1]
+			}
+		}
+		Signature.Date = r.Header.Get("X-Amz-Date")
+		if Signature.Date == "" {
+			if Date, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+				Signature.Date = Date.UTC().Format(signatureTimeFormat)
+			}
+		}
+	} else if Query := r.URL.Query(); Query.Get("X-Amz-Signature") != "" {
+		if Query.Get("X-Amz-Algorithm") != signatureAlgorithm {
+			return Signature, false, "IncompleteSignature", "Unsupported AWS signature algorithm, only AWS4-HMAC-SHA256 is supported."
+		}
+		Signature.Presigned = true
+		Signature.AccessKeyID, Signature.Scope = splitCredential(Query.Get("X-Amz-Credential"))
+		Signature.SignedHeaders = strings.Split(Query.Get("X-Amz-SignedHeaders"), ";")
+		Signature.Signature = Query.Get("X-Amz-Signature")
+		Signature.Date = Query.Get("X-Amz-Date")
+		var err error
+		if Signature.Expires, err = strconv.Atoi(Query.Get("X-Amz-Expires")); err != nil || Signature.Expires < 0 || Signature.Expires > limits.MaxPresignedExpires {
+			return Signature, false, "AuthorizationQueryParametersError", fmt.Sprintf("X-Amz-Expires must be a number between 0 and %d.", limits.MaxPresignedExpires)
+		}
+	} else {
+		return Signature, false, "MissingAuthenticationToken", "Request is missing Authentication Token"
+	}
+
+	if Signature.AccessKeyID == "" || Signature.Scope == "" || len(Signature.SignedHeaders) == 0 || Signature.Signature == "" || Signature.Date == "" {
+		return Signature, false, "IncompleteSignature", "The request signature does not conform to AWS standards."
+	}
+
+	return Signature, true, "", ""
+}
+
+func splitCredential(Credential string) (string, string) {
+	var Parts = strings.SplitN(Credential, "/", 2)
+	if len(Parts) != 2 {
+		return Parts[0], ""
+	}
+
+	return Parts[0], Parts[1]
+}
+
+func canonicalRequest(r *http.Request, Body []byte, Signature signature) string {
+	var CanonicalHeaders strings.Builder
+	for _, Name := range Signature.SignedHeaders {
+		var RawValues = r.Header[http.CanonicalHeaderKey(Name)]
+		if Name == "host" {
+			RawValues = []string{r.Host}
+		}
+		var Values = make([]string, len(RawValues))
+		for i, Value := range RawValues {
+			Values[i] = strings.Join(strings.Fields(Value), " ")
+		}
+		fmt.Fprintf(&CanonicalHeaders, "%s:%s\n", Name, strings.Join(Values, ","))
+	}
+
+	var PayloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if PayloadHash == "" {
+		if Signature.Presigned {
+			PayloadHash = unsignedPayload
+		} else {
+			PayloadHash = hashHex(Body)
+		}
+	}
+
+	var CanonicalURI = r.URL.EscapedPath()
+	if CanonicalURI == "" {
+		CanonicalURI = "/"
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		escapePath(CanonicalURI),
+		canonicalQueryString(r.URL.Query()),
+		CanonicalHeaders.String(),
+		strings.Join(Signature.SignedHeaders, ";"),
+		PayloadHash,
+	}, "\n")
+}
+
+// canonicalQueryString sorts parameters by encoded name and then by encoded value, sorting whole pairs
+// would misplace a name which is a prefix of another one, e.g. AttributeName and AttributeName.1.
+func canonicalQueryString(Query url.Values) string {
+	var Pairs [][2]string
+	for Key, Values := range Query {
+		if Key == "X-Amz-Signature" {
+			continue
+		}
+		for _, Value := range Values {
+			Pairs = append(Pairs, [2]string{escape(Key), escape(Value)})
+		}
+	}
+	sort.Slice(Pairs, func(i, j int) bool {
+		if Pairs[i][0] != Pairs[j][0] {
+			return Pairs[i][0] < Pairs[j][0]
+		}
+		return Pairs[i][1] < Pairs[j][1]
+	})
+
+	var Parts = make([]string, len(Pairs))
+	for i, Pair := range Pairs {
+		Parts[i] = Pair[0] + "=" + Pair[1]
+	}
+
+	return strings.Join(Parts, "&")
+}
+
+// escapePath escapes an already escaped path once more, as SDKs do for all services except S3.
+func escapePath(Path string) string {
+	var Segments = strings.Split(Path, "/")
+	for i, Segment := range Segments {
+		Segments[i] = escape(Segment)
+	}
+
+	return strings.Join(Segments, "/")
+}
+
+// escape percent-encodes everything except unreserved characters of RFC 3986.
+func escape(Value string) string {
+	var Result strings.Builder
+	for _, Byte := range []byte(Value) {
+		if ('A' <= Byte && Byte <= 'Z') || ('a' <= Byte && Byte <= 'z') || ('0' <= Byte && Byte <= '9') ||
+			Byte == '-' || Byte == '_' || Byte == '.' || Byte == '~' {
+			Result.WriteByte(Byte)
+		} else {
+			fmt.Fprintf(&Result, "%%%02X", Byte)
+		}
+	}
+
+	return Result.String()
+}
+
+func hashHex(Data []byte) string {
+	var Hash = sha256.Sum256(Data)
+	return hex.EncodeToString(Hash[:])
+}
+
+func hmacSHA256(Key []byte, Data string) []byte {
+	var Hash = hmac.New(sha256.New, Key)
+	Hash.Write([]byte(Data))
+	return Hash.Sum(nil)
+}
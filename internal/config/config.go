@@ -0,0 +1,116 @@
+// Package config defines configuration of the server, which is loaded from a JSON file.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+// DefaultAccountID is an account which owns queues unless configured otherwise.
+const DefaultAccountID = "000000000000"
+
+// DefaultRegion is a region of queues unless configured otherwise.
+const DefaultRegion = "us-east-1"
+
+// Config represents configuration of the server.
+type Config struct {
+	// AccountID is an account of callers with unknown access keys and of unsigned requests.
+	AccountID string `json:"accountId"`
+	// Region is a region of queues of callers whose requests are not signed for a particular region.
+	Region string `json:"region"`
+	// Endpoint is a base URL of queue URLs, e.g. http://sqs.example.com:8080.
+	// Queue URLs are based on Host header of a request when it is empty.
+	Endpoint string `json:"endpoint"`
+	// AccessKeys maps access key IDs to accounts of callers.
+	// Callers with unknown access keys are considered to belong to AccountID.
+	AccessKeys map[string]string `json:"accessKeys"`
+	// EnforcePolicies enables evaluation of queue policies, requests which are not allowed by them
+	// are rejected with AccessDenied.
+	EnforcePolicies bool `json:"enforcePolicies"`
+	// QueuePolicies maps queue ARNs to policies which apply in addition to their Policy attribute.
+	// ARNs rather than names are used since queues of different accounts and regions could share a name.
+	QueuePolicies map[string]*queue.Policy `json:"queuePolicies"`
+	// VerifySignatures enables verification of SigV4 signatures of requests
+	// with secret keys from CredentialsFile.
+	VerifySignatures bool `json:"verifySignatures"`
+	// CredentialsFile is a path to a file in the format of AWS shared credentials file.
+	CredentialsFile string `json:"credentialsFile"`
+	// Credentials maps access key IDs to secret keys loaded from CredentialsFile.
+	Credentials map[string]string `json:"-"`
+}
+
+// Default returns a configuration used when no configuration file is given.
+func Default() *Config {
+	return &Config{
+		AccountID: DefaultAccountID,
+		Region:    DefaultRegion,
+	}
+}
+
+// Load reads configuration from a JSON file, options missing from the file keep their default values.
+func Load(Path string) (*Config, error) {
+	var Data, err = os.ReadFile(Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var Config = Default()
+	if err = json.Unmarshal(Data, Config); err != nil {
+		return nil, err
+	}
+
+	for QueueArn := range Config.QueuePolicies {
+		if !isQueueArn(QueueArn) {
+			return nil, fmt.Errorf("queuePolicies key %s is not a queue ARN", QueueArn)
+		}
+	}
+
+	if Config.CredentialsFile != "" {
+		if Config.Credentials, err = loadCredentials(Config.CredentialsFile); err != nil {
+			return nil, err
+		}
+	} else if Config.VerifySignatures {
+		return nil, errors.New("credentialsFile is required to verify signatures")
+	}
+
+	return Config, nil
+}
+
+// isQueueArn checks that a value looks like arn:aws:sqs:region:account:name.
+func isQueueArn(Value string) bool {
+	var Parts = strings.Split(Value, ":")
+	return len(Parts) == 6 && Parts[0] == "arn" && Parts[2] == "sqs" && Parts[3] != "" && Parts[4] != "" && Parts[5] != ""
+}
+
+// AccountForAccessKey returns an account of a caller with given access key ID.
+func (Config *Config) AccountForAccessKey(AccessKeyID string) string {
+	if AccountID, ok := Config.AccessKeys[AccessKeyID]; ok {
+		return AccountID
+	}
+
+	return Config.AccountID
+}
+
+// RegionOf returns a region of a caller, which is Region unless the request was signed for another one.
+func (Config *Config) RegionOf(SignedRegion string) string {
+	if SignedRegion != "" {
+		return SignedRegion
+	}
+
+	return Config.Region
+}
+
+// BaseURL returns a base URL of queue URLs for a request.
+func (Config *Config) BaseURL(r *http.Request) string {
+	if Config.Endpoint != "" {
+		return strings.TrimSuffix(Config.Endpoint, "/")
+	}
+
+	return "http://" + r.Host
+}
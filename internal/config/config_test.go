@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, Data string) string {
+	t.Helper()
+	var Path = filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(Path, []byte(Data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return Path
+}
+
+func TestLoadQueuePolicies(t *testing.T) {
+	var Path = writeTestConfig(t, `{
+		"enforcePolicies": true,
+		"queuePolicies": {
+			"arn:aws:sqs:us-east-1:000000000000:my-queue": {"Statement": [{"Effect": "Allow", "Principal": {"AWS": "111111111111"}, "Action": "sqs:SendMessage"}]}
+		}
+	}`)
+
+	var Config, err = Load(Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Config.AccountID != DefaultAccountID || Config.Region != DefaultRegion {
+		t.Fatalf("expected default account and region, got %s and %s", Config.AccountID, Config.Region)
+	}
+	var Policy = Config.QueuePolicies["arn:aws:sqs:us-east-1:000000000000:my-queue"]
+	if Policy == nil || len(Policy.Statement) != 1 || Policy.Statement[0].Effect != "Allow" {
+		t.Fatalf("expected policy of my-queue with one statement, got %+v", Policy)
+	}
+	if _, ok := Config.QueuePolicies["my-queue"]; ok {
+		t.Fatal("expected policy not to be found by queue name")
+	}
+}
+
+func TestLoadQueuePoliciesByName(t *testing.T) {
+	var Path = writeTestConfig(t, `{
+		"queuePolicies": {
+			"my-queue": {"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "sqs:SendMessage"}]}
+		}
+	}`)
+
+	if _, err := Load(Path); err == nil {
+		t.Fatal("expected a policy keyed by queue name to be rejected")
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// loadCredentials reads access keys and secret keys of all profiles from a file
+// in the format of AWS shared credentials file.
+func loadCredentials(Path string) (map[string]string, error) {
+	var File, err = os.Open(Path)
+	if err != nil {
+		return nil, err
+	}
+	defer File.Close()
+
+	var Credentials = make(map[string]string)
+	var AccessKeyID, SecretAccessKey string
+	var addProfile = func() {
+		if AccessKeyID != "" && SecretAccessKey != "" {
+			Credentials[AccessKeyID] = SecretAccessKey
+		}
+		AccessKeyID, SecretAccessKey = "", ""
+	}
+
+	var Scanner = bufio.NewScanner(File)
+	for Scanner.Scan() {
+		var Line = strings.TrimSpace(Scanner.Text())
+		if Line == "" || strings.HasPrefix(Line, "#") || strings.HasPrefix(Line, ";") {
+			continue
+		}
+		if strings.HasPrefix(Line, "[") {
+			addProfile()
+			continue
+		}
+
+		var KeyValue = strings.SplitN(Line, "=", 2)
+		if len(KeyValue) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(KeyValue[0]) {
+		case "aws_access_key_id":
+			AccessKeyID = strings.TrimSpace(KeyValue[1])
+		case "aws_secret_access_key":
+			SecretAccessKey = strings.TrimSpace(KeyValue[1])
+		}
+	}
+	addProfile()
+
+	return Credentials, Scanner.Err()
+}
@@ -1,9 +1,26 @@
 package events
 
-// ReceiveRequestEvent represent a request to receive a message
+// QueueVisibilityTimeout is used as a VisibilityTimeout of ReceiveRequestEvent
+// to apply the default visibility timeout of the queue.
+const QueueVisibilityTimeout = -1
+
+// QueueDelaySeconds is used as a DelaySeconds of SendRequestEvent
+// to apply the default delay of the queue.
+const QueueDelaySeconds = -1
+
+// QueueWaitTimeSeconds is used as a WaitTimeSeconds of ReceiveRequestEvent
+// to apply the default receive wait time of the queue.
+const QueueWaitTimeSeconds = -1
+
+// ReceiveRequestEvent represent a request to receive a message.
+// If there are no visible messages, the request waits up to WaitTimeSeconds for them
+// or until Done is closed. ReturnChan must be buffered so that the queue actor does not block
+// on requests which are already abandoned.
 type ReceiveRequestEvent struct {
 	MaxNumberOfMessages int
 	VisibilityTimeout   int
+	WaitTimeSeconds     int
+	Done                <-chan struct{}
 	ReturnChan          chan ReceiveResponseEvent
 }
 
@@ -22,4 +39,114 @@ type DeleteRequestEvent struct {
 // DeleteResponseEvent represents a response to a request to delete a message.
 type DeleteResponseEvent struct {
 	Ok bool
+	// ErrorCode and ErrorMessage are set if the message could not be deleted
+	// for a reason other than an invalid receipt handle.
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// ChangeVisibilityRequestEvent represents a request to change visibility timeout of a message.
+type ChangeVisibilityRequestEvent struct {
+	ReceiptHandle     string
+	VisibilityTimeout int
+	ReturnChan        chan ChangeVisibilityResponseEvent
+}
+
+// ChangeVisibilityResponseEvent represents a response to a request to change visibility timeout of a message.
+type ChangeVisibilityResponseEvent struct {
+	Ok           bool
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// PurgeRequestEvent represents a request to purge all messages from a queue.
+type PurgeRequestEvent struct {
+	ReturnChan chan PurgeResponseEvent
+}
+
+// PurgeResponseEvent represents a response to a request to purge a queue.
+type PurgeResponseEvent struct {
+	Ok bool
+}
+
+// GetAttributesRequestEvent represents a request to get a consistent snapshot of attributes of a queue.
+type GetAttributesRequestEvent struct {
+	ReturnChan chan GetAttributesResponseEvent
+}
+
+// GetAttributesResponseEvent represents a response to a request to get attributes of a queue.
+// Attributes contain all attributes of a queue formatted as in responses, unset ones are empty.
+type GetAttributesResponseEvent struct {
+	Attributes map[string]string
+}
+
+// SetAttributesRequestEvent represents a request to set attributes of a queue.
+type SetAttributesRequestEvent struct {
+	Attributes map[string]string
+	ReturnChan chan SetAttributesResponseEvent
+}
+
+// SetAttributesResponseEvent represents a response to a request to set attributes of a queue.
+type SetAttributesResponseEvent struct {
+	Ok bool
+}
+
+// PermissionRequestEvent represents a request to add a statement labeled with Label
+// to a queue policy, or to remove it if Statement is nil.
+type PermissionRequestEvent struct {
+	Label string
+	// TODO: Break dependency cycle and make Statement a concrete type
+	Statement  interface{}
+	ReturnChan chan PermissionResponseEvent
+}
+
+// PermissionResponseEvent represents a response to a request to change a queue policy.
+type PermissionResponseEvent struct {
+	Ok           bool
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// TagRequestEvent represents a request to add and remove tags of a queue.
+// Tags from RemoveTagKeys are removed before AddTags are added.
+type TagRequestEvent struct {
+	AddTags       map[string]string
+	RemoveTagKeys []string
+	ReturnChan    chan TagResponseEvent
+}
+
+// TagResponseEvent represents a response to a request to change tags of a queue.
+// Tags is a copy of queue tags after the change.
+type TagResponseEvent struct {
+	Ok           bool
+	ErrorCode    string
+	ErrorMessage string
+	Tags         map[string]string
+}
+
+// SendRequestEvent represents a request to send a message. Checks which depend on queue attributes,
+// such as message size, delay and FIFO parameters, are done by the queue actor.
+type SendRequestEvent struct {
+	// TODO: Break dependency cycle and make Message a concrete type
+	Message      interface{}
+	MessageSize  int
+	DelaySeconds int
+	ReturnChan   chan SendResponseEvent
+}
+
+// RedriveRequestEvent represents a message moved to a dead-letter queue from its source queue.
+// The dead-letter queue drops the message if its RedriveAllowPolicy does not permit the source queue.
+type RedriveRequestEvent struct {
+	// TODO: Break dependency cycle and make Message a concrete type
+	Message interface{}
+}
+
+// SendResponseEvent represents a response to a request to send a message.
+// For a deduplicated message MessageID and SequenceNumber are those of the originally sent message.
+type SendResponseEvent struct {
+	Ok             bool
+	ErrorCode      string
+	ErrorMessage   string
+	MessageID      string
+	SequenceNumber string
 }
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/andreyst/go-sqs/internal/auth"
+	"github.com/andreyst/go-sqs/internal/config"
+	"github.com/andreyst/go-sqs/internal/queue"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
+	"github.com/andreyst/go-sqs/internal/server"
+	"github.com/andreyst/go-sqs/internal/util"
+)
+
+// Authorize checks whether a caller is allowed to perform an action on a queue from QueueUrl parameter,
+// or from QueueName parameter for GetQueueUrl. Queue owner is allowed unless a policy denies it,
+// other accounts need to be allowed by a policy. Requests which do not address an existing queue
+// are always allowed, so that they fail as usual.
+func Authorize(req server.Request, AccessRequest auth.AccessRequest, Queues *queuemgr.Manager, Config *config.Config) bool {
+	var Queue *queue.Queue
+	if QueueURL := req.Params.Get("QueueUrl"); QueueURL != "" {
+		Queue = Queues.GetByURL(req.AccountID, req.Region, QueueURL)
+	} else if QueueName := req.Params.Get("QueueName"); QueueName != "" && AccessRequest.Action == "GetQueueUrl" {
+		Queue = Queues.GetByName(queueOwner(req), req.Region, QueueName)
+	}
+	if Queue == nil {
+		return true
+	}
+
+	// Policy attribute is owned by the queue actor, so it is read from a snapshot of attributes
+	var Attributes, ok = getQueueAttributes(Queue)
+	if !ok {
+		return true
+	}
+	var Policy, _ = util.ParsePolicy(Attributes["Policy"])
+
+	AccessRequest.Resource = Queue.QueueArn
+	var Decision = auth.EvaluatePolicies([]*queue.Policy{Policy, Config.QueuePolicies[Queue.QueueArn]}, AccessRequest)
+	if Decision == auth.Deny {
+		return false
+	}
+
+	return Decision == auth.Allow || AccessRequest.AccountID == Queue.AccountID
+}
@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/andreyst/go-sqs/internal/events"
 	"github.com/andreyst/go-sqs/internal/limits"
 	"github.com/andreyst/go-sqs/internal/queue"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
 	"github.com/andreyst/go-sqs/internal/server"
 	"github.com/andreyst/go-sqs/internal/util"
 	"github.com/andreyst/go-sqs/internal/validation"
@@ -69,29 +74,699 @@ func validateBatch(Parameters url.Values, BatchPrefix string, RequiredKeys []str
 	return BatchValidationResult
 }
 
+func parseAttributes(Parameters url.Values, AttributePrefix string) map[string]string {
+	var Attributes = make(map[string]string)
+	for i := 1; ; i++ {
+		var Name = Parameters.Get(fmt.Sprintf("%s.%d.Name", AttributePrefix, i))
+		if Name == "" {
+			break
+		}
+		Attributes[Name] = Parameters.Get(fmt.Sprintf("%s.%d.Value", AttributePrefix, i))
+	}
+
+	return Attributes
+}
+
+var queueAttributeBounds = map[string][2]int{
+	"DelaySeconds":                  {0, limits.MaxDelaySeconds},
+	"KmsDataKeyReusePeriodSeconds":  {limits.MinKmsDataKeyReusePeriodSeconds, limits.MaxKmsDataKeyReusePeriodSeconds},
+	"MaximumMessageSize":            {limits.MinMaximumMessageSize, limits.MaxMaximumMessageSize},
+	"MessageRetentionPeriod":        {limits.MinMessageRetentionPeriod, limits.MaxMessageRetentionPeriod},
+	"ReceiveMessageWaitTimeSeconds": {0, limits.MaxReceiveMessageWaitTimeSeconds},
+	"VisibilityTimeout":             {0, limits.MaxVisibilityTimeout},
+}
+
+// queueAttributeValues lists allowed values of queue attributes which are enumerations.
+var queueAttributeValues = map[string][]string{
+	"DeduplicationScope":  {"messageGroup", "queue"},
+	"FifoThroughputLimit": {"perMessageGroupId", "perQueue"},
+}
+
+// fifoQueueAttributeNames lists attributes which are known only to FIFO queues, besides FifoQueue itself.
+var fifoQueueAttributeNames = []string{"ContentBasedDeduplication", "DeduplicationScope", "FifoThroughputLimit"}
+
+// validateQueueAttributes also normalizes valid values in place so they could be compared
+// with values of an existing queue.
+func validateQueueAttributes(Attributes map[string]string) (bool, string, string) {
+	for Name, Value := range Attributes {
+		switch Name {
+		case "ContentBasedDeduplication", "FifoQueue", "SqsManagedSseEnabled":
+			var BoolValue, err = strconv.ParseBool(Value)
+			if err != nil {
+				return false, "InvalidAttributeValue", fmt.Sprintf("Invalid value for the parameter %s.", Name)
+			}
+			Attributes[Name] = strconv.FormatBool(BoolValue)
+			continue
+		case "RedrivePolicy":
+			if Value == "" {
+				continue
+			}
+			var RedrivePolicy, ok = util.ParseRedrivePolicy(Value)
+			if !ok {
+				return false, "InvalidParameterValue", fmt.Sprintf("Value %s for parameter RedrivePolicy is invalid. Reason: Redrive policy is not a valid JSON map or contains invalid deadLetterTargetArn or maxReceiveCount between 1 and %d.", Value, limits.MaxMaxReceiveCount)
+			}
+			var NormalizedValue, _ = json.Marshal(RedrivePolicy)
+			Attributes[Name] = string(NormalizedValue)
+			continue
+		case "Policy":
+			if Value == "" {
+				continue
+			}
+			var Policy, ok = util.ParsePolicy(Value)
+			if !ok {
+				return false, "InvalidAttributeValue", "Invalid value for the parameter Policy."
+			}
+			var NormalizedValue, _ = json.Marshal(Policy)
+			Attributes[Name] = string(NormalizedValue)
+			continue
+		case "RedriveAllowPolicy":
+			if Value == "" {
+				continue
+			}
+			var RedriveAllowPolicy, ok = util.ParseRedriveAllowPolicy(Value)
+			if !ok {
+				return false, "InvalidParameterValue", fmt.Sprintf("Value %s for parameter RedriveAllowPolicy is invalid. Reason: Amazon SQS can't create the redrive allow policy, as it's in an unsupported format.", Value)
+			}
+			var NormalizedValue, _ = json.Marshal(RedriveAllowPolicy)
+			Attributes[Name] = string(NormalizedValue)
+			continue
+		case "DeduplicationScope", "FifoThroughputLimit":
+			if !isOneOf(Value, queueAttributeValues[Name]) {
+				return false, "InvalidAttributeValue", fmt.Sprintf("Invalid value for the parameter %s.", Name)
+			}
+			continue
+		case "KmsMasterKeyId":
+			continue
+		}
+
+		var Bounds, ok = queueAttributeBounds[Name]
+		if !ok {
+			return false, "InvalidAttributeName", fmt.Sprintf("Unknown Attribute %s.", Name)
+		}
+
+		var IntValue, err = strconv.Atoi(Value)
+		if err != nil || IntValue < Bounds[0] || IntValue > Bounds[1] {
+			return false, "InvalidAttributeValue", fmt.Sprintf("Invalid value for the parameter %s.", Name)
+		}
+		Attributes[Name] = strconv.Itoa(IntValue)
+	}
+
+	if Attributes["KmsMasterKeyId"] != "" && Attributes["SqsManagedSseEnabled"] == "true" {
+		return false, "InvalidAttributeValue", "You can use one type of server-side encryption (SSE) at one time. You can either enable KMS SSE or SQS SSE."
+	}
+
+	return true, "", ""
+}
+
+func isOneOf(Value string, AllowedValues []string) bool {
+	for _, AllowedValue := range AllowedValues {
+		if Value == AllowedValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateFifoQueueAttributes checks that FIFO-only attributes are not set on a standard queue.
+func validateFifoQueueAttributes(IsFifoQueue bool, Attributes map[string]string) (bool, string, string) {
+	if IsFifoQueue {
+		return true, "", ""
+	}
+	for _, Name := range fifoQueueAttributeNames {
+		if _, ok := Attributes[Name]; ok {
+			return false, "InvalidAttributeName", fmt.Sprintf("Unknown Attribute %s.", Name)
+		}
+	}
+
+	return true, "", ""
+}
+
+// validateRedrivePolicy checks that dead-letter queue from a RedrivePolicy exists and allows
+// the source queue to use it. Attributes are expected to pass validateQueueAttributes beforehand.
+func validateRedrivePolicy(Queues *queuemgr.Manager, SourceQueueArn string, IsFifoQueue bool, Attributes map[string]string) (bool, string, string) {
+	var Value = Attributes["RedrivePolicy"]
+	if Value == "" {
+		return true, "", ""
+	}
+
+	var RedrivePolicy, _ = util.ParseRedrivePolicy(Value)
+	var DeadLetterQueue = Queues.GetByArn(RedrivePolicy.DeadLetterTargetArn)
+	var DeadLetterQueueAttributes map[string]string
+	var ok bool
+	if DeadLetterQueue != nil {
+		DeadLetterQueueAttributes, ok = getQueueAttributes(DeadLetterQueue)
+	}
+	if !ok {
+		return false, "InvalidParameterValue", fmt.Sprintf("Value %s for parameter RedrivePolicy is invalid. Reason: Dead letter target does not exist.", Value)
+	}
+	if (DeadLetterQueueAttributes["FifoQueue"] == "true") != IsFifoQueue {
+		return false, "InvalidParameterValue", fmt.Sprintf("Value %s for parameter RedrivePolicy is invalid. Reason: Dead-letter queue must be same type of queue as the source.", Value)
+	}
+	// Empty value means that there is no RedriveAllowPolicy and any source queue is allowed
+	var RedriveAllowPolicy, _ = util.ParseRedriveAllowPolicy(DeadLetterQueueAttributes["RedriveAllowPolicy"])
+	if !util.IsRedriveAllowed(RedriveAllowPolicy, SourceQueueArn) {
+		return false, "InvalidParameterValue", fmt.Sprintf("Value %s for parameter RedrivePolicy is invalid. Reason: Dead-letter queue %s does not allow this queue to use it as a dead-letter queue.", Value, DeadLetterQueue.QueueArn)
+	}
+
+	return true, "", ""
+}
+
+var messageAttributeNamePattern = regexp.MustCompile("^[a-zA-Z0-9_\\-.]+$")
+var messageAttributeDataTypePattern = regexp.MustCompile("^(String|Number|Binary)(\\..+)?$")
+
+// parseMessageAttributes parses message attributes from parameters starting with AttributePrefix,
+// e.g. MessageAttribute or SendMessageBatchRequestEntry.1.MessageAttribute.
+func parseMessageAttributes(Parameters url.Values, AttributePrefix string) (map[string]queue.MessageAttributeValue, bool, string, string) {
+	var Attributes = make(map[string]queue.MessageAttributeValue)
+	for i := 1; ; i++ {
+		var Name = Parameters.Get(fmt.Sprintf("%s.%d.Name", AttributePrefix, i))
+		if Name == "" {
+			break
+		}
+
+		if i == limits.MaxMessageAttributes+1 {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("Number of message attributes exceeds the allowed maximum [%d].", limits.MaxMessageAttributes)
+		}
+
+		var LowerCaseName = strings.ToLower(Name)
+		if len(Name) > limits.MaxMessageAttributeNameLength || !messageAttributeNamePattern.MatchString(Name) ||
+			strings.HasPrefix(Name, ".") || strings.HasSuffix(Name, ".") || strings.Contains(Name, "..") ||
+			strings.HasPrefix(LowerCaseName, "aws.") || strings.HasPrefix(LowerCaseName, "amazon.") {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("Message attribute name '%s' is invalid.", Name)
+		}
+		if _, ok := Attributes[Name]; ok {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("Message attribute name '%s' is already in use.", Name)
+		}
+
+		var Value = queue.MessageAttributeValue{
+			DataType:    Parameters.Get(fmt.Sprintf("%s.%d.Value.DataType", AttributePrefix, i)),
+			StringValue: Parameters.Get(fmt.Sprintf("%s.%d.Value.StringValue", AttributePrefix, i)),
+		}
+		if Value.DataType == "" {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("The message attribute '%s' must contain non-empty message attribute type.", Name)
+		}
+		if len(Value.DataType) > limits.MaxMessageAttributeNameLength || !messageAttributeDataTypePattern.MatchString(Value.DataType) {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("The type of message attribute '%s' is invalid. You must use only the following supported type prefixes: Binary, Number, String.", Name)
+		}
+
+		if strings.HasPrefix(Value.DataType, "Binary") {
+			var RawBinaryValue = Parameters.Get(fmt.Sprintf("%s.%d.Value.BinaryValue", AttributePrefix, i))
+			var err error
+			if Value.BinaryValue, err = base64.StdEncoding.DecodeString(RawBinaryValue); err != nil {
+				return nil, false, "InvalidParameterValue", fmt.Sprintf("The message attribute '%s' contains invalid Base64 binary value.", Name)
+			}
+			if len(Value.BinaryValue) == 0 {
+				return nil, false, "InvalidParameterValue", fmt.Sprintf("The message attribute '%s' must contain non-empty message attribute value for message attribute type '%s'.", Name, Value.DataType)
+			}
+		} else {
+			if Value.StringValue == "" {
+				return nil, false, "InvalidParameterValue", fmt.Sprintf("The message attribute '%s' must contain non-empty message attribute value for message attribute type '%s'.", Name, Value.DataType)
+			}
+			// XML encoder would replace such characters, so the receiver would get a different value
+			if Character, ok := findInvalidCharacter(Value.StringValue); ok {
+				return nil, false, "InvalidParameterValue", fmt.Sprintf("Message attribute '%s' value contains invalid binary character '#x%X', the set of allowed characters is #x9 | #xA | #xD | #x20 to #xD7FF | #xE000 to #xFFFD | #x10000 to #x10FFFF", Name, Character)
+			}
+			if strings.HasPrefix(Value.DataType, "Number") {
+				if _, err := strconv.ParseFloat(Value.StringValue, 64); err != nil {
+					return nil, false, "InvalidParameterValue", fmt.Sprintf("Can't cast the value of message attribute '%s' to a number.", Name)
+				}
+			}
+		}
+
+		Attributes[Name] = Value
+	}
+
+	return Attributes, true, "", ""
+}
+
+// parseMessageSystemAttributes parses message system attributes from parameters starting with AttributePrefix.
+// AWSTraceHeader is the only supported one, TraceHeader from X-Amzn-Trace-Id HTTP header is used when it is not given.
+func parseMessageSystemAttributes(Parameters url.Values, AttributePrefix string, TraceHeader string) (map[string]queue.MessageAttributeValue, bool, string, string) {
+	var Attributes = make(map[string]queue.MessageAttributeValue)
+	for i := 1; ; i++ {
+		var Name = Parameters.Get(fmt.Sprintf("%s.%d.Name", AttributePrefix, i))
+		if Name == "" {
+			break
+		}
+		if Name != "AWSTraceHeader" {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("Message system attribute name '%s' is invalid.", Name)
+		}
+
+		var Value = queue.MessageAttributeValue{
+			DataType:    Parameters.Get(fmt.Sprintf("%s.%d.Value.DataType", AttributePrefix, i)),
+			StringValue: Parameters.Get(fmt.Sprintf("%s.%d.Value.StringValue", AttributePrefix, i)),
+		}
+		if Value.DataType != "String" {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("The message system attribute '%s' must be of type String.", Name)
+		}
+		if Value.StringValue == "" {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("The message system attribute '%s' must contain non-empty message system attribute value.", Name)
+		}
+		Attributes[Name] = Value
+	}
+
+	if _, ok := Attributes["AWSTraceHeader"]; !ok && TraceHeader != "" {
+		Attributes["AWSTraceHeader"] = queue.MessageAttributeValue{DataType: "String", StringValue: TraceHeader}
+	}
+
+	return Attributes, true, "", ""
+}
+
+// filterMessageAttributes returns attributes requested by names, which could be All, .* or prefixes like foo.*
+func filterMessageAttributes(Attributes map[string]queue.MessageAttributeValue, Names []string) map[string]queue.MessageAttributeValue {
+	var FilteredAttributes = make(map[string]queue.MessageAttributeValue)
+	for _, RequestedName := range Names {
+		if RequestedName == "All" || RequestedName == ".*" {
+			return Attributes
+		}
+
+		if strings.HasSuffix(RequestedName, ".*") {
+			var Prefix = strings.TrimSuffix(RequestedName, "*")
+			for Name, Value := range Attributes {
+				if strings.HasPrefix(Name, Prefix) {
+					FilteredAttributes[Name] = Value
+				}
+			}
+		} else if Value, ok := Attributes[RequestedName]; ok {
+			FilteredAttributes[RequestedName] = Value
+		}
+	}
+
+	return FilteredAttributes
+}
+
+// messageSystemAttributeNames lists system attributes of a message which could be requested by ReceiveMessage.
+var messageSystemAttributeNames = []string{
+	"AWSTraceHeader",
+	"ApproximateFirstReceiveTimestamp",
+	"ApproximateReceiveCount",
+	"DeadLetterQueueSourceArn",
+	"MessageDeduplicationId",
+	"MessageGroupId",
+	"SenderId",
+	"SentTimestamp",
+	"SequenceNumber",
+}
+
+// filterMessageSystemAttributes returns system attributes of a message requested by names, which could be All.
+// Attributes which a message does not have, e.g. FIFO ones of a standard queue message, are omitted.
+func filterMessageSystemAttributes(Message *queue.Message, Names []string) server.AttributeMap {
+	if isOneOf("All", Names) {
+		Names = messageSystemAttributeNames
+	}
+
+	var Attributes = server.AttributeMap{}
+	for _, Name := range Names {
+		var Value string
+		switch Name {
+		case "AWSTraceHeader":
+			Value = Message.AWSTraceHeader
+		case "ApproximateFirstReceiveTimestamp":
+			Value = strconv.FormatInt(Message.ApproximateFirstReceiveTimestamp, 10)
+		case "ApproximateReceiveCount":
+			Value = strconv.Itoa(Message.ApproximateReceiveCount)
+		case "DeadLetterQueueSourceArn":
+			Value = Message.DeadLetterQueueSourceArn
+		case "MessageDeduplicationId":
+			Value = Message.MessageDeduplicationID
+		case "MessageGroupId":
+			Value = Message.MessageGroupID
+		case "SenderId":
+			Value = Message.SenderID
+		case "SentTimestamp":
+			Value = strconv.FormatInt(Message.SentTimestamp, 10)
+		case "SequenceNumber":
+			Value = Message.SequenceNumber
+		}
+		if Value != "" {
+			Attributes[Name] = Value
+		}
+	}
+
+	return Attributes
+}
+
+// findInvalidCharacter returns the first character of a message body or of a string attribute value
+// which could not be represented in XML. Bytes which are not valid UTF-8 are reported as U+FFFD.
+func findInvalidCharacter(Value string) (rune, bool) {
+	for i, Character := range Value {
+		if Character == utf8.RuneError {
+			if _, Size := utf8.DecodeRuneInString(Value[i:]); Size == 1 {
+				return Character, true
+			}
+		}
+		if Character == 0x9 || Character == 0xA || Character == 0xD ||
+			(Character >= 0x20 && Character <= 0xD7FF) || (Character >= 0xE000 && Character <= 0xFFFD) || Character >= 0x10000 {
+			continue
+		}
+		return Character, true
+	}
+
+	return 0, false
+}
+
+func messageSize(MessageBody string, Attributes map[string]queue.MessageAttributeValue) int {
+	var Size = len(MessageBody)
+	for Name, Value := range Attributes {
+		Size += len(Name) + len(Value.DataType) + len(Value.StringValue) + len(Value.BinaryValue)
+	}
+
+	return Size
+}
+
+func parseTags(Parameters url.Values) map[string]string {
+	var Tags = make(map[string]string)
+	for i := 1; ; i++ {
+		var Key, ok = Parameters[fmt.Sprintf("Tag.%d.Key", i)]
+		if !ok {
+			break
+		}
+		Tags[Key[0]] = Parameters.Get(fmt.Sprintf("Tag.%d.Value", i))
+	}
+
+	return Tags
+}
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+func validateTagKey(Key string) (bool, string, string) {
+	var Length = utf8.RuneCountInString(Key)
+	if Length < 1 || Length > limits.MaxTagKeyLength {
+		return false, "InvalidParameterValue", fmt.Sprintf("Tag keys must be between 1 and %d characters in length.", limits.MaxTagKeyLength)
+	}
+	if !tagPattern.MatchString(Key) {
+		return false, "InvalidParameterValue", fmt.Sprintf("Tag key %s contains invalid characters.", Key)
+	}
+	if strings.HasPrefix(strings.ToLower(Key), "aws:") {
+		return false, "InvalidParameterValue", fmt.Sprintf("Tag key %s uses reserved prefix aws:.", Key)
+	}
+
+	return true, "", ""
+}
+
+func validateTags(Tags map[string]string) (bool, string, string) {
+	if len(Tags) > limits.MaxQueueTags {
+		return false, "InvalidParameterValue", fmt.Sprintf("Too many tags, a queue can have up to %d tags.", limits.MaxQueueTags)
+	}
+	for Key, Value := range Tags {
+		if ok, ErrorCode, ErrorMessage := validateTagKey(Key); !ok {
+			return false, ErrorCode, ErrorMessage
+		}
+		if utf8.RuneCountInString(Value) > limits.MaxTagValueLength {
+			return false, "InvalidParameterValue", fmt.Sprintf("Tag values may only be up to %d characters in length.", limits.MaxTagValueLength)
+		}
+		if !tagPattern.MatchString(Value) {
+			return false, "InvalidParameterValue", fmt.Sprintf("Tag value for key %s contains invalid characters.", Key)
+		}
+	}
+
+	return true, "", ""
+}
+
+func tagQueue(Queue *queue.Queue, AddTags map[string]string, RemoveTagKeys []string) events.TagResponseEvent {
+	var ReturnChan = make(chan events.TagResponseEvent)
+	select {
+	case Queue.TagChannel <- events.TagRequestEvent{
+		AddTags:       AddTags,
+		RemoveTagKeys: RemoveTagKeys,
+		ReturnChan:    ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return events.TagResponseEvent{Ok: false, ErrorCode: "AWS.SimpleQueueService.NonExistentQueue", ErrorMessage: "The specified queue does not exist for this wsdl version."}
+	}
+
+	var event = <-ReturnChan
+
+	return event
+}
+
+var permissionLabelPattern = regexp.MustCompile(fmt.Sprintf("^[a-zA-Z0-9_\\-]{1,%d}$", limits.MaxPermissionLabelLength))
+var accountIDPattern = regexp.MustCompile("^[0-9]{12}$")
+
+// permissionActions lists actions which could be granted with AddPermission.
+var permissionActions = map[string]bool{
+	"*":                          true,
+	"ChangeMessageVisibility":    true,
+	"DeleteMessage":              true,
+	"GetQueueAttributes":         true,
+	"GetQueueUrl":                true,
+	"ListDeadLetterSourceQueues": true,
+	"PurgeQueue":                 true,
+	"ReceiveMessage":             true,
+	"SendMessage":                true,
+}
+
+func changePermission(Queue *queue.Queue, Label string, Statement *queue.PolicyStatement) events.PermissionResponseEvent {
+	var ReturnChan = make(chan events.PermissionResponseEvent)
+	var Event = events.PermissionRequestEvent{
+		Label:      Label,
+		ReturnChan: ReturnChan,
+	}
+	// Typed nil would not be recognized as a removal by the queue actor
+	if Statement != nil {
+		Event.Statement = Statement
+	}
+	select {
+	case Queue.PermissionChannel <- Event:
+	case <-Queue.Deleted:
+		return events.PermissionResponseEvent{Ok: false, ErrorCode: "AWS.SimpleQueueService.NonExistentQueue", ErrorMessage: "The specified queue does not exist for this wsdl version."}
+	}
+
+	var event = <-ReturnChan
+
+	return event
+}
+
+// AddPermission grants actions on a queue to other accounts with a labeled statement of the queue policy.
+func AddPermission(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Label = req.Params.Get("Label")
+	if Label == "" {
+		return resp.Error("MissingParameter", "The request must contain the parameter Label.")
+	}
+	if !permissionLabelPattern.MatchString(Label) {
+		return resp.Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter Label is invalid. Reason: Must match pattern ^[a-zA-Z0-9_-]{1,%d}$.", Label, limits.MaxPermissionLabelLength))
+	}
+
+	var Principals queue.PolicyValues
+	for i := 1; ; i++ {
+		var AccountID, ok = req.Params[fmt.Sprintf("AWSAccountId.%d", i)]
+		if !ok {
+			break
+		}
+		if !accountIDPattern.MatchString(AccountID[0]) {
+			return resp.Error("InvalidParameterValue", "Value for parameter PrincipalId is invalid. Reason: Unable to verify.")
+		}
+		Principals = append(Principals, fmt.Sprintf("arn:aws:iam::%s:root", AccountID[0]))
+	}
+	if len(Principals) == 0 {
+		return resp.Error("MissingParameter", "The request must contain the parameter AWSAccountIds.")
+	}
+	if len(Principals) > limits.MaxPermissionPrincipals {
+		return resp.Error("OverLimit", fmt.Sprintf("%d AWSAccountIds were found, maximum allowed is %d.", len(Principals), limits.MaxPermissionPrincipals))
+	}
+
+	var Actions queue.PolicyValues
+	for i := 1; ; i++ {
+		var ActionName, ok = req.Params[fmt.Sprintf("ActionName.%d", i)]
+		if !ok {
+			break
+		}
+		if !permissionActions[ActionName[0]] {
+			return resp.Error("InvalidParameterValue", fmt.Sprintf("Value SQS:%s for parameter ActionName is invalid. Reason: Only the queue owner is allowed to invoke this action.", ActionName[0]))
+		}
+		Actions = append(Actions, "SQS:"+ActionName[0])
+	}
+	if len(Actions) == 0 {
+		return resp.Error("MissingParameter", "The request must contain the parameter Actions.")
+	}
+	if len(Actions) > limits.MaxPermissionActions {
+		return resp.Error("OverLimit", fmt.Sprintf("%d Actions were found, maximum allowed is %d.", len(Actions), limits.MaxPermissionActions))
+	}
+
+	var PermissionResponseEvent = changePermission(Queue, Label, &queue.PolicyStatement{
+		Sid:       Label,
+		Effect:    "Allow",
+		Principal: queue.PolicyPrincipal{"AWS": Principals},
+		Action:    Actions,
+		Resource:  queue.PolicyValues{Queue.QueueArn},
+	})
+	if !PermissionResponseEvent.Ok {
+		return resp.Error(PermissionResponseEvent.ErrorCode, PermissionResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("AddPermission", nil)
+}
+
+func changeMessageVisibility(Queue *queue.Queue, ReceiptHandle string, RawVisibilityTimeout string) events.ChangeVisibilityResponseEvent {
+	if RawVisibilityTimeout == "" {
+		return events.ChangeVisibilityResponseEvent{
+			Ok:           false,
+			ErrorCode:    "MissingParameter",
+			ErrorMessage: "A required parameter VisibilityTimeout is not supplied.",
+		}
+	}
+
+	var VisibilityTimeout, err = strconv.Atoi(RawVisibilityTimeout)
+	if err != nil || VisibilityTimeout < 0 || VisibilityTimeout > limits.MaxVisibilityTimeout {
+		return events.ChangeVisibilityResponseEvent{
+			Ok:           false,
+			ErrorCode:    "InvalidParameterValue",
+			ErrorMessage: fmt.Sprintf("Value %s for parameter VisibilityTimeout is invalid. Reason: Must be between 0 and %d, if provided.", RawVisibilityTimeout, limits.MaxVisibilityTimeout),
+		}
+	}
+
+	var ReturnChan = make(chan events.ChangeVisibilityResponseEvent)
+	select {
+	case Queue.ChangeVisibilityChannel <- events.ChangeVisibilityRequestEvent{
+		ReceiptHandle:     ReceiptHandle,
+		VisibilityTimeout: VisibilityTimeout,
+		ReturnChan:        ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return events.ChangeVisibilityResponseEvent{Ok: false, ErrorCode: "AWS.SimpleQueueService.NonExistentQueue", ErrorMessage: "The specified queue does not exist for this wsdl version."}
+	}
+
+	var event = <-ReturnChan
+
+	return event
+}
+
+// ChangeMessageVisibility sets a new visibility timeout of an in flight message, counting from now.
+func ChangeMessageVisibility(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	// TODO: Validate ReceiptHandle format
+	var ReceiptHandle = req.Params.Get("ReceiptHandle")
+	var ChangeVisibilityResponseEvent = changeMessageVisibility(Queue, ReceiptHandle, req.Params.Get("VisibilityTimeout"))
+	if !ChangeVisibilityResponseEvent.Ok {
+		return resp.Error(ChangeVisibilityResponseEvent.ErrorCode, ChangeVisibilityResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("ChangeMessageVisibility", nil)
+}
+
+// ChangeMessageVisibilityBatch changes visibility timeouts of up to 10 in flight messages, reporting failures per entry.
+func ChangeMessageVisibilityBatch(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var BatchValidationResult = validateBatch(req.Params, "ChangeMessageVisibilityBatchRequestEntry", []string{
+		"Id",
+		"ReceiptHandle",
+	}[:])
+	if !BatchValidationResult.Ok {
+		return resp.Error(BatchValidationResult.ErrorCode, BatchValidationResult.ErrorMessage)
+	}
+
+	var Result = server.ChangeMessageVisibilityBatchResult{
+		Successful: []server.ChangeMessageVisibilityBatchResultEntry{},
+		Failed:     []server.BatchResultErrorEntry{},
+	}
+	for i := 1; i <= BatchValidationResult.BatchSize; i++ {
+		var BatchEntryID = req.Params.Get(fmt.Sprintf("ChangeMessageVisibilityBatchRequestEntry.%d.Id", i))
+		// TODO: Validate ReceiptHandle format
+		var ReceiptHandle = req.Params.Get(fmt.Sprintf("ChangeMessageVisibilityBatchRequestEntry.%d.ReceiptHandle", i))
+		var RawVisibilityTimeout = req.Params.Get(fmt.Sprintf("ChangeMessageVisibilityBatchRequestEntry.%d.VisibilityTimeout", i))
+
+		var ChangeVisibilityResponseEvent = changeMessageVisibility(Queue, ReceiptHandle, RawVisibilityTimeout)
+		if ChangeVisibilityResponseEvent.Ok {
+			Result.Successful = append(Result.Successful, server.ChangeMessageVisibilityBatchResultEntry{ID: BatchEntryID})
+		} else {
+			Result.Failed = append(Result.Failed, server.BatchResultErrorEntry{
+				ID:          BatchEntryID,
+				Code:        ChangeVisibilityResponseEvent.ErrorCode,
+				Message:     ChangeVisibilityResponseEvent.ErrorMessage,
+				SenderFault: true,
+			})
+		}
+	}
+
+	return resp.Success("ChangeMessageVisibilityBatch", Result)
+}
+
 // CreateQueue TODO: add comment
-func CreateQueue(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
+func CreateQueue(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
 	var QueueName = req.Params.Get("QueueName")
 	// TODO: Move validation to a separate validator
 	if QueueName == "" {
 		return resp.Error("MissingParameter", "A required parameter QueueName is not supplied.")
 	}
 
-	var IsValidQueueName, err = regexp.MatchString("^[a-zA-Z0-9_\\-]{1,80}$", QueueName)
-	if !IsValidQueueName || err != nil {
-		return resp.Error("InvalidParameterValue", "The specified queue name is not valid.")
+	var Attributes = parseAttributes(req.Params, "Attribute")
+	var AttributesOk, ErrorCode, ErrorMessage = validateQueueAttributes(Attributes)
+	if !AttributesOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+
+	var IsFifoQueue = Attributes["FifoQueue"] == "true"
+	if IsFifoQueue {
+		var IsValidQueueName, err = regexp.MatchString("^[a-zA-Z0-9_\\-]{1,75}\\.fifo$", QueueName)
+		if !IsValidQueueName || err != nil {
+			return resp.Error("InvalidParameterValue", "The name of a FIFO queue can only include alphanumeric characters, hyphens, or underscores, must end with .fifo suffix and be 1 to 80 in length.")
+		}
+	} else {
+		var IsValidQueueName, err = regexp.MatchString("^[a-zA-Z0-9_\\-]{1,80}$", QueueName)
+		if !IsValidQueueName || err != nil {
+			return resp.Error("InvalidParameterValue", "The specified queue name is not valid.")
+		}
+	}
+
+	AttributesOk, ErrorCode, ErrorMessage = validateFifoQueueAttributes(IsFifoQueue, Attributes)
+	if !AttributesOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+	AttributesOk, ErrorCode, ErrorMessage = validateRedrivePolicy(Queues, queuemgr.QueueArn(req.Region, req.AccountID, QueueName), IsFifoQueue, Attributes)
+	if !AttributesOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+
+	var Tags = parseTags(req.Params)
+	var TagsOk bool
+	TagsOk, ErrorCode, ErrorMessage = validateTags(Tags)
+	if !TagsOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+
+	// Existing queue is checked after creation attempt, so that concurrent requests do not create duplicates
+	var Queue, Created = util.CreateQueue(Queues, req.AccountID, req.Region, QueueName, Attributes, Tags)
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.QueueDeletedRecently", fmt.Sprintf("You must wait %d seconds after deleting a queue before you can create another with the same name.", limits.DeletedQueueNameReuseInterval))
+	}
+	if !Created {
+		var ExistingAttributes, ok = getQueueAttributes(Queue)
+		if !ok {
+			return resp.Error("AWS.SimpleQueueService.QueueDeletedRecently", fmt.Sprintf("You must wait %d seconds after deleting a queue before you can create another with the same name.", limits.DeletedQueueNameReuseInterval))
+		}
+		for Name, Value := range Attributes {
+			if ExistingAttributes[Name] != Value {
+				return resp.Error("QueueAlreadyExists", fmt.Sprintf("A queue already exists with the same name and a different value for attribute %s", Name))
+			}
+		}
 	}
 
-	var _, QueueURL = util.CreateQueue(Queues, QueueName)
-	var CreateQueueResult = fmt.Sprintf("<QueueUrl>%s</QueueUrl>", QueueURL)
-	return resp.Success("CreateQueue", CreateQueueResult)
+	return resp.Success("CreateQueue", server.CreateQueueResult{QueueURL: queuemgr.QueueURL(req.BaseURL, Queue)})
 }
 
 func deleteMessage(Queue *queue.Queue, ReceiptHandle string) events.DeleteResponseEvent {
 	var ReturnChan = make(chan events.DeleteResponseEvent)
-	Queue.DeleteChannel <- events.DeleteRequestEvent{
+	select {
+	case Queue.DeleteChannel <- events.DeleteRequestEvent{
 		ReceiptHandle: ReceiptHandle,
 		ReturnChan:    ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return events.DeleteResponseEvent{Ok: false, ErrorCode: "AWS.SimpleQueueService.NonExistentQueue", ErrorMessage: "The specified queue does not exist for this wsdl version."}
 	}
 
 	var event = <-ReturnChan
@@ -100,32 +775,31 @@ func deleteMessage(Queue *queue.Queue, ReceiptHandle string) events.DeleteRespon
 }
 
 // DeleteMessage TODO: add comment
-func DeleteMessage(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
-	if !ok {
+func DeleteMessage(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	var Queue = QueuePtr.(*queue.Queue)
 
 	// TODO: Validate ReceiptHandle format
 	var ReceiptHandle = req.Params.Get("ReceiptHandle")
 	var DeleteResponseEvent = deleteMessage(Queue, ReceiptHandle)
+	if !DeleteResponseEvent.Ok && DeleteResponseEvent.ErrorCode != "" {
+		return resp.Error(DeleteResponseEvent.ErrorCode, DeleteResponseEvent.ErrorMessage)
+	}
 	if !DeleteResponseEvent.Ok {
 		return resp.Error("ReceiptHandleIsInvalid", fmt.Sprintf("The input receipt handle \"%s\" is not a valid receipt handle.", ReceiptHandle))
 	}
 
-	return resp.Success("DeleteMessage", "")
+	return resp.Success("DeleteMessage", nil)
 }
 
 // DeleteMessageBatch TODO: add comment
-func DeleteMessageBatch(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
-	if !ok {
+func DeleteMessageBatch(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	var Queue = QueuePtr.(*queue.Queue)
 
 	var BatchValidationResult = validateBatch(req.Params, "DeleteMessageBatchRequestEntry", []string{
 		"Id",
@@ -135,8 +809,10 @@ func DeleteMessageBatch(req server.Request, resp server.Response, Queues *sync.M
 		return resp.Error(BatchValidationResult.ErrorCode, BatchValidationResult.ErrorMessage)
 	}
 
-	var SuccessfulResult = ""
-	var ErrorResult = ""
+	var Result = server.DeleteMessageBatchResult{
+		Successful: []server.DeleteMessageBatchResultEntry{},
+		Failed:     []server.BatchResultErrorEntry{},
+	}
 	for i := 1; i <= BatchValidationResult.BatchSize; i++ {
 		var ReceiptHandleID = req.Params.Get(fmt.Sprintf("DeleteMessageBatchRequestEntry.%d.Id", i))
 		// TODO: Validate ReceiptHandle format
@@ -144,136 +820,255 @@ func DeleteMessageBatch(req server.Request, resp server.Response, Queues *sync.M
 
 		var DeleteResponseEvent = deleteMessage(Queue, ReceiptHandle)
 		if DeleteResponseEvent.Ok {
-			SuccessfulResult += fmt.Sprintf("<DeleteMessageBatchResultEntry><Id>%s</Id></DeleteMessageBatchResultEntry>", ReceiptHandleID)
+			Result.Successful = append(Result.Successful, server.DeleteMessageBatchResultEntry{ID: ReceiptHandleID})
+		} else if DeleteResponseEvent.ErrorCode != "" {
+			Result.Failed = append(Result.Failed, server.BatchResultErrorEntry{
+				ID:          ReceiptHandleID,
+				Code:        DeleteResponseEvent.ErrorCode,
+				Message:     DeleteResponseEvent.ErrorMessage,
+				SenderFault: true,
+			})
 		} else {
-			ErrorResult += fmt.Sprintf("<BatchResultErrorEntry><Id>%s</Id><Code>ReceiptHandleIsInvalid</Code><Message>The input receipt handle is invalid.</Message><SenderFault>true</SenderFault></BatchResultErrorEntry>", ReceiptHandleID)
+			Result.Failed = append(Result.Failed, server.BatchResultErrorEntry{
+				ID:          ReceiptHandleID,
+				Code:        "ReceiptHandleIsInvalid",
+				Message:     "The input receipt handle is invalid.",
+				SenderFault: true,
+			})
 		}
 	}
 
-	var Result = SuccessfulResult + ErrorResult
-
 	return resp.Success("DeleteMessageBatch", Result)
 }
 
 // DeleteQueue TODO: add comment
-func DeleteQueue(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var _, ok = Queues.Load(QueueURL)
-	if !ok {
+func DeleteQueue(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil || !Queues.Delete(Queue) {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	Queues.Delete(QueueURL)
 
-	return resp.Success("DeleteQueue", "")
+	return resp.Success("DeleteQueue", nil)
+}
+
+// getQueueAttributes returns a snapshot of all attributes of a queue taken by the queue actor.
+// It returns false if the queue is deleted meanwhile.
+func getQueueAttributes(Queue *queue.Queue) (map[string]string, bool) {
+	var ReturnChan = make(chan events.GetAttributesResponseEvent)
+	select {
+	case Queue.GetAttributesChannel <- events.GetAttributesRequestEvent{
+		ReturnChan: ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return nil, false
+	}
+
+	var event = <-ReturnChan
+
+	return event.Attributes, true
 }
 
 // GetQueueAttributes TODO: add comment
-func GetQueueAttributes(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
+func GetQueueAttributes(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Attributes, ok = getQueueAttributes(Queue)
 	if !ok {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	var Queue = QueuePtr.(*queue.Queue)
-
-	var Result = fmt.Sprintf(`<Attribute>
-	<Name>QueueArn</Name>
-	<Value>%s</Value>
-  </Attribute>
-  <Attribute>
-	<Name>ApproximateNumberOfMessages</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>ApproximateNumberOfMessagesNotVisible</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>ApproximateNumberOfMessagesDelayed</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>CreatedTimestamp</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>LastModifiedTimestamp</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>VisibilityTimeout</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>MaximumMessageSize</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>MessageRetentionPeriod</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>DelaySeconds</Name>
-	<Value>%d</Value>
-  </Attribute>
-  <Attribute>
-	<Name>ReceiveMessageWaitTimeSeconds</Name>
-	<Value>%d</Value>
-  </Attribute>`,
-		Queue.QueueArn,
-		Queue.ApproximateNumberOfMessages,
-		Queue.ApproximateNumberOfMessagesNotVisible,
-		Queue.ApproximateNumberOfMessagesDelayed,
-		Queue.CreatedTimestamp,
-		Queue.LastModifiedTimestamp,
-		Queue.VisibilityTimeout,
-		Queue.MaximumMessageSize,
-		Queue.MessageRetentionPeriod,
-		Queue.DelaySeconds,
-		Queue.ReceiveMessageWaitTimeSeconds)
+
+	// Requests without attribute names get all attributes, as they always did here
+	var Names []string
+	for i := 1; ; i++ {
+		var Name = req.Params.Get(fmt.Sprintf("AttributeName.%d", i))
+		if Name == "" {
+			break
+		}
+		if _, ok := Attributes[Name]; !ok && Name != "All" {
+			return resp.Error("InvalidAttributeName", fmt.Sprintf("Unknown Attribute %s.", Name))
+		}
+		Names = append(Names, Name)
+	}
+	if len(Names) == 0 || isOneOf("All", Names) {
+		Names = Names[:0]
+		for Name := range Attributes {
+			Names = append(Names, Name)
+		}
+	}
+
+	var Result = server.GetQueueAttributesResult{
+		Attributes: server.AttributeMap{},
+	}
+	for _, Name := range Names {
+		if isQueueAttributeReturned(Attributes, Name) {
+			Result.Attributes[Name] = Attributes[Name]
+		}
+	}
 
 	return resp.Success("GetQueueAttributes", Result)
 }
 
+// isQueueAttributeReturned checks whether an attribute is returned by GetQueueAttributes. Policies and KMS key
+// are returned only when set, KMS settings only with KMS key, and FIFO attributes only for FIFO queues.
+func isQueueAttributeReturned(Attributes map[string]string, Name string) bool {
+	if Attributes[Name] == "" {
+		return false
+	}
+	if Name == "KmsDataKeyReusePeriodSeconds" {
+		return Attributes["KmsMasterKeyId"] != ""
+	}
+	if Name == "FifoQueue" || isOneOf(Name, fifoQueueAttributeNames) {
+		return Attributes["FifoQueue"] == "true"
+	}
+
+	return true
+}
+
 // GetQueueURL TODO: add comment
-func GetQueueURL(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
+func GetQueueURL(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
 	var QueueName = req.Params.Get("QueueName")
 	// TODO: Validate QueueName
-	var Queue, QueueURL = util.GetQueueByName(Queues, QueueName)
+	var Queue = Queues.GetByName(queueOwner(req), req.Region, QueueName)
 	if Queue == nil {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
 
-	var GetQueueURLResult = fmt.Sprintf("<QueueUrl>%s</QueueUrl>", QueueURL)
-	return resp.Success("GetQueueUrl", GetQueueURLResult)
+	return resp.Success("GetQueueUrl", server.GetQueueURLResult{QueueURL: queuemgr.QueueURL(req.BaseURL, Queue)})
+}
+
+// queueOwner returns an account which owns a queue addressed by name, which is the caller account
+// unless QueueOwnerAWSAccountId parameter is given.
+func queueOwner(req server.Request) string {
+	if QueueOwner := req.Params.Get("QueueOwnerAWSAccountId"); QueueOwner != "" {
+		return QueueOwner
+	}
+
+	return req.AccountID
 }
 
-// ListQueues TODO: add comment
-func ListQueues(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var Result = ""
-	Queues.Range(func(QueueURL, v interface{}) bool {
-		Result += fmt.Sprintf("<QueueUrl>%s</QueueUrl>", QueueURL)
-		return true
+// ListDeadLetterSourceQueues lists URLs of queues whose RedrivePolicy targets the given queue.
+func ListDeadLetterSourceQueues(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var DeadLetterQueue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if DeadLetterQueue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Result = server.ListDeadLetterSourceQueuesResult{
+		QueueURLs: []string{},
+	}
+	// Only queues of the caller could be sources, other accounts and regions are not even asked
+	var CandidateQueues = Queues.Filter(func(SourceQueue *queue.Queue) bool {
+		return SourceQueue.AccountID == req.AccountID && SourceQueue.Region == req.Region
+	})
+	// RedrivePolicy is owned by the actor of each queue, queues deleted meanwhile are skipped
+	for _, SourceQueue := range CandidateQueues {
+		var Attributes, ok = getQueueAttributes(SourceQueue)
+		if !ok || Attributes["RedrivePolicy"] == "" {
+			continue
+		}
+		var RedrivePolicy, _ = util.ParseRedrivePolicy(Attributes["RedrivePolicy"])
+		if RedrivePolicy.DeadLetterTargetArn == DeadLetterQueue.QueueArn {
+			Result.QueueURLs = append(Result.QueueURLs, queuemgr.QueueURL(req.BaseURL, SourceQueue))
+		}
+	}
+	return resp.Success("ListDeadLetterSourceQueues", Result)
+}
+
+// ListQueues returns queues of the caller sorted by name.
+// NextToken is returned only when MaxResults is given, it is a base64-encoded name of the last returned queue.
+func ListQueues(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var RawMaxResults = req.Params.Get("MaxResults")
+	var MaxResults = limits.MaxListQueuesResults
+	if RawMaxResults != "" {
+		var err error
+		MaxResults, err = strconv.Atoi(RawMaxResults)
+		if err != nil || MaxResults < 1 || MaxResults > limits.MaxListQueuesResults {
+			return resp.Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter MaxResults is invalid. Reason: Must be between 1 and %d", RawMaxResults, limits.MaxListQueuesResults))
+		}
+	}
+
+	var RawNextToken = req.Params.Get("NextToken")
+	var StartAfter, err = base64.URLEncoding.DecodeString(RawNextToken)
+	if err != nil {
+		return resp.Error("InvalidParameterValue", "Invalid NextToken value.")
+	}
+
+	var FoundQueues = Queues.List(req.AccountID, req.Region, req.Params.Get("QueueNamePrefix"))
+	var Start = sort.Search(len(FoundQueues), func(i int) bool {
+		return FoundQueues[i].QueueName > string(StartAfter)
 	})
+	FoundQueues = FoundQueues[Start:]
+
+	var Result = server.ListQueuesResult{}
+	if len(FoundQueues) > MaxResults {
+		FoundQueues = FoundQueues[:MaxResults]
+		if RawMaxResults != "" {
+			Result.NextToken = base64.URLEncoding.EncodeToString([]byte(FoundQueues[MaxResults-1].QueueName))
+		}
+	}
+	for _, Queue := range FoundQueues {
+		Result.QueueURLs = append(Result.QueueURLs, queuemgr.QueueURL(req.BaseURL, Queue))
+	}
+
 	return resp.Success("ListQueues", Result)
 }
 
+// ListQueueTags returns all tags of a queue.
+func ListQueueTags(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var TagResponseEvent = tagQueue(Queue, nil, nil)
+	if !TagResponseEvent.Ok {
+		return resp.Error(TagResponseEvent.ErrorCode, TagResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("ListQueueTags", server.ListQueueTagsResult{Tags: TagResponseEvent.Tags})
+}
+
+// PurgeQueue deletes all messages of a queue, a queue could be purged once in 60 seconds.
+func PurgeQueue(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var ReturnChan = make(chan events.PurgeResponseEvent)
+	select {
+	case Queue.PurgeChannel <- events.PurgeRequestEvent{
+		ReturnChan: ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var PurgeResponseEvent = <-ReturnChan
+	if !PurgeResponseEvent.Ok {
+		return resp.Error("AWS.SimpleQueueService.PurgeQueueInProgress", fmt.Sprintf("Only one PurgeQueue operation on %s is allowed every %d seconds.", Queue.QueueName, limits.PurgeQueueInterval))
+	}
+
+	return resp.Success("PurgeQueue", nil)
+}
+
 // ReceiveMessage TODO: add comment
-func ReceiveMessage(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
-	if !ok {
+func ReceiveMessage(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	var Queue = QueuePtr.(*queue.Queue)
 	var RawVisibilityTimeout = req.Params.Get("VisibilityTimeout")
-	var VisibilityTimeout = 30
-	// TODO: Validate VisibilityTimeout value properly
+	// Queue's default visibility timeout is resolved by the queue actor to avoid racing with SetQueueAttributes
+	var VisibilityTimeout = events.QueueVisibilityTimeout
 	if RawVisibilityTimeout != "" {
 		var err error
 		VisibilityTimeout, err = strconv.Atoi(RawVisibilityTimeout)
-		if err != nil {
-			return resp.Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter VisibilityTimeout is invalid. Reason: Must be between 0 and 43200, if provided", RawVisibilityTimeout))
+		if err != nil || VisibilityTimeout < 0 || VisibilityTimeout > limits.MaxVisibilityTimeout {
+			return resp.Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter VisibilityTimeout is invalid. Reason: Must be between 0 and %d, if provided.", RawVisibilityTimeout, limits.MaxVisibilityTimeout))
 		}
 	}
 
@@ -287,118 +1082,261 @@ func ReceiveMessage(req server.Request, resp server.Response, Queues *sync.Map)
 		}
 	}
 
-	var ReturnChan = make(chan events.ReceiveResponseEvent)
-	Queue.ReceiveChannel <- events.ReceiveRequestEvent{
+	var RawWaitTimeSeconds = req.Params.Get("WaitTimeSeconds")
+	var WaitTimeSeconds = events.QueueWaitTimeSeconds
+	if RawWaitTimeSeconds != "" {
+		var err error
+		WaitTimeSeconds, err = strconv.Atoi(RawWaitTimeSeconds)
+		if err != nil || WaitTimeSeconds < 0 || WaitTimeSeconds > limits.MaxReceiveMessageWaitTimeSeconds {
+			return resp.Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter WaitTimeSeconds is invalid. Reason: Must be >= 0 and <= %d, if provided.", RawWaitTimeSeconds, limits.MaxReceiveMessageWaitTimeSeconds))
+		}
+	}
+
+	var MessageAttributeNames []string
+	for i := 1; ; i++ {
+		var MessageAttributeName = req.Params.Get(fmt.Sprintf("MessageAttributeName.%d", i))
+		if MessageAttributeName == "" {
+			break
+		}
+		MessageAttributeNames = append(MessageAttributeNames, MessageAttributeName)
+	}
+
+	// AttributeName is a deprecated form of MessageSystemAttributeName, names from both are combined
+	var SystemAttributeNames []string
+	for _, Prefix := range []string{"AttributeName", "MessageSystemAttributeName"} {
+		for i := 1; ; i++ {
+			var SystemAttributeName = req.Params.Get(fmt.Sprintf("%s.%d", Prefix, i))
+			if SystemAttributeName == "" {
+				break
+			}
+			if SystemAttributeName != "All" && !isOneOf(SystemAttributeName, messageSystemAttributeNames) {
+				return resp.Error("InvalidAttributeName", fmt.Sprintf("Unknown Attribute %s.", SystemAttributeName))
+			}
+			SystemAttributeNames = append(SystemAttributeNames, SystemAttributeName)
+		}
+	}
+
+	var ReturnChan = make(chan events.ReceiveResponseEvent, 1)
+	select {
+	case Queue.ReceiveChannel <- events.ReceiveRequestEvent{
 		MaxNumberOfMessages: MaxNumberOfMessages,
 		VisibilityTimeout:   VisibilityTimeout,
+		WaitTimeSeconds:     WaitTimeSeconds,
+		Done:                req.Context.Done(),
 		ReturnChan:          ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
 
-	var ReceiveResponseEvent = <-ReturnChan
+	var ReceiveResponseEvent events.ReceiveResponseEvent
+	select {
+	case ReceiveResponseEvent = <-ReturnChan:
+	case <-Queue.Deleted:
+		// Parked request is abandoned by the stopped queue actor
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	case <-req.Context.Done():
+		// Client is gone, nobody will read the response
+		return resp.Success("ReceiveMessage", nil)
+	}
 
-	var ReceiveMessageResult = ""
+	var Result = server.ReceiveMessageResult{}
 	for i := 0; i < len(ReceiveResponseEvent.Messages); i++ {
 		var FoundMessage = ReceiveResponseEvent.Messages[i].(*queue.Message)
 
-		ReceiveMessageResult += fmt.Sprintf(`<Message>
-		<MessageId>%s</MessageId>
-		<ReceiptHandle>%s</ReceiptHandle>
-		<MD5OfBody>%s</MD5OfBody>
-		<Body>%s</Body>
-		<Attribute>
-			<Name>SenderId</Name>
-			<Value>%s</Value>
-		</Attribute>
-		<Attribute>
-			<Name>SentTimestamp</Name>
-			<Value>%d</Value>
-		</Attribute>
-		<Attribute>
-			<Name>ApproximateReceiveCount</Name>
-			<Value>%d</Value>
-		</Attribute>
-		<Attribute>
-			<Name>ApproximateFirstReceiveTimestamp</Name>
-			<Value>%d</Value>
-		</Attribute>
-		</Message>`, FoundMessage.MessageID, FoundMessage.ReceiptHandle, FoundMessage.MD5OfMessageBody, FoundMessage.Body, FoundMessage.SenderID, FoundMessage.SentTimestamp, FoundMessage.ApproximateReceiveCount, FoundMessage.ApproximateFirstReceiveTimestamp)
-	}
-
-	return resp.Success("ReceiveMessage", ReceiveMessageResult)
-}
-
-func sendMessage(Queue *queue.Queue, MessageBody string, DelaySeconds int) (*queue.Message, bool, string, string) {
-	// TODO: Move validation of delay seconds to this method
-	// TODO: Calculate MD5 of message body
-	var MD5OfMessageBody = ""
-	// TODO: Calculate MD5 of message attributes
-	var MD5OfMessageAttributes = ""
-
-	if DelaySeconds < 0 || DelaySeconds > 900 {
-		return nil, false, "InvalidParameterValue", fmt.Sprintf("Value %d for parameter DelaySeconds is invalid. Reason: Must be between 0 and 900, if provided.", DelaySeconds)
-	}
-
-	var VisibilityDeadline int64
-	if DelaySeconds > 0 {
-		VisibilityDeadline = time.Now().Unix() + int64(DelaySeconds)
+		var MessageResult = server.MessageResult{
+			MessageID:     FoundMessage.MessageID,
+			ReceiptHandle: FoundMessage.ReceiptHandle,
+			MD5OfBody:     FoundMessage.MD5OfMessageBody,
+			Body:          FoundMessage.Body,
+		}
+		var SystemAttributes = filterMessageSystemAttributes(FoundMessage, SystemAttributeNames)
+		if len(SystemAttributes) > 0 {
+			MessageResult.Attributes = SystemAttributes
+		}
+
+		var MessageAttributes = filterMessageAttributes(FoundMessage.MessageAttributes, MessageAttributeNames)
+		if len(MessageAttributes) > 0 {
+			MessageResult.MD5OfMessageAttributes = util.MD5OfMessageAttributes(MessageAttributes)
+			MessageResult.MessageAttributes = server.MessageAttributeMap{}
+		}
+		for Name, Value := range MessageAttributes {
+			var ValueResult = server.MessageAttributeValueResult{DataType: Value.DataType}
+			if strings.HasPrefix(Value.DataType, "Binary") {
+				ValueResult.BinaryValue = base64.StdEncoding.EncodeToString(Value.BinaryValue)
+			} else {
+				ValueResult.StringValue = Value.StringValue
+			}
+			MessageResult.MessageAttributes[Name] = ValueResult
+		}
+
+		Result.Messages = append(Result.Messages, MessageResult)
 	}
 
-	var Message = queue.Message{
-		MessageID:              uuid.Must(uuid.NewV4()).String(),
-		MD5OfMessageBody:       MD5OfMessageBody,
-		MD5OfMessageAttributes: MD5OfMessageAttributes,
-		Body:     MessageBody,
-		SenderID: "",
-		ApproximateFirstReceiveTimestamp: 0,
-		ApproximateReceiveCount:          0,
-		SentTimestamp:                    time.Now().Unix(),
-		VisibilityDeadline:               VisibilityDeadline,
+	return resp.Success("ReceiveMessage", Result)
+}
+
+// RemovePermission removes a statement added by AddPermission from the queue policy by its label.
+func RemovePermission(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
 
-	Queue.SendChannel <- &Message
-	return &Message, true, "", ""
+	var Label = req.Params.Get("Label")
+	if Label == "" {
+		return resp.Error("MissingParameter", "The request must contain the parameter Label.")
+	}
+
+	var PermissionResponseEvent = changePermission(Queue, Label, nil)
+	if !PermissionResponseEvent.Ok {
+		return resp.Error(PermissionResponseEvent.ErrorCode, PermissionResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("RemovePermission", nil)
 }
 
-// SendMessage TODO: add comment
-func SendMessage(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
+// sendMessage sends a message built from request parameters starting with Prefix, which is empty
+// for SendMessage and points to a batch entry for SendMessageBatch.
+func sendMessage(Queue *queue.Queue, req server.Request, Prefix string) (*queue.Message, bool, string, string) {
+	var MessageBody = req.Params.Get(Prefix + "MessageBody")
+	var MessageAttributes, ok, ErrorCode, ErrorMessage = parseMessageAttributes(req.Params, Prefix+"MessageAttribute")
 	if !ok {
-		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+		return nil, false, ErrorCode, ErrorMessage
+	}
+	// System attributes do not count towards message size
+	var SystemAttributes map[string]queue.MessageAttributeValue
+	SystemAttributes, ok, ErrorCode, ErrorMessage = parseMessageSystemAttributes(req.Params, Prefix+"MessageSystemAttribute", req.TraceHeader)
+	if !ok {
+		return nil, false, ErrorCode, ErrorMessage
+	}
+	if Character, ok := findInvalidCharacter(MessageBody); ok {
+		return nil, false, "InvalidMessageContents", fmt.Sprintf("Invalid binary character '#x%X' was found in the message body, the set of allowed characters is #x9 | #xA | #xD | #x20 to #xD7FF | #xE000 to #xFFFD | #x10000 to #x10FFFF", Character)
 	}
-	var Queue = QueuePtr.(*queue.Queue)
 
-	var RawDelaySeconds = req.Params.Get("DelaySeconds")
-	var DelaySeconds = Queue.DelaySeconds
+	var RawDelaySeconds = req.Params.Get(Prefix + "DelaySeconds")
+	var DelaySeconds = events.QueueDelaySeconds
 	if RawDelaySeconds != "" {
 		var err error
 		if DelaySeconds, err = strconv.Atoi(RawDelaySeconds); err != nil {
-			return resp.Error("InvalidParameterValue", fmt.Sprintf("Parameter DelaySeconds should be of type Integer"))
+			return nil, false, "InvalidParameterValue", "Parameter DelaySeconds should be of type Integer"
+		}
+		if DelaySeconds < 0 || DelaySeconds > limits.MaxDelaySeconds {
+			return nil, false, "InvalidParameterValue", fmt.Sprintf("Value %d for parameter DelaySeconds is invalid. Reason: Must be between 0 and %d, if provided.", DelaySeconds, limits.MaxDelaySeconds)
 		}
 	}
 
-	var Message *queue.Message
-	var ErrorCode, ErrorMessage string
-	Message, ok, ErrorCode, ErrorMessage = sendMessage(Queue, req.Params.Get("MessageBody"), DelaySeconds)
+	var Message = queue.Message{
+		MessageID:                        uuid.Must(uuid.NewV4()).String(),
+		MD5OfMessageBody:                 util.MD5OfMessageBody(MessageBody),
+		MD5OfMessageAttributes:           util.MD5OfMessageAttributes(MessageAttributes),
+		MD5OfMessageSystemAttributes:     util.MD5OfMessageAttributes(SystemAttributes),
+		MessageAttributes:                MessageAttributes,
+		Body:                             MessageBody,
+		SenderID:                         req.AccountID,
+		ApproximateFirstReceiveTimestamp: 0,
+		ApproximateReceiveCount:          0,
+		SentTimestamp:                    time.Now().UnixNano() / int64(time.Millisecond),
+		MessageGroupID:                   req.Params.Get(Prefix + "MessageGroupId"),
+		MessageDeduplicationID:           req.Params.Get(Prefix + "MessageDeduplicationId"),
+		AWSTraceHeader:                   SystemAttributes["AWSTraceHeader"].StringValue,
+	}
 
-	if !ok {
+	// Queue actor owns the sent copy, this one is used only to build a response
+	var SentMessage = Message
+	var ReturnChan = make(chan events.SendResponseEvent)
+	select {
+	case Queue.SendChannel <- events.SendRequestEvent{
+		Message:      &SentMessage,
+		MessageSize:  messageSize(MessageBody, MessageAttributes),
+		DelaySeconds: DelaySeconds,
+		ReturnChan:   ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return nil, false, "AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version."
+	}
+
+	var SendResponseEvent = <-ReturnChan
+	if !SendResponseEvent.Ok {
+		return nil, false, SendResponseEvent.ErrorCode, SendResponseEvent.ErrorMessage
+	}
+
+	// Deduplicated message is reported as the originally sent one
+	Message.MessageID = SendResponseEvent.MessageID
+	Message.SequenceNumber = SendResponseEvent.SequenceNumber
+	return &Message, true, "", ""
+}
+
+// SetQueueAttributes changes attributes of an existing queue, FifoQueue could only be set on creation.
+func SetQueueAttributes(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Attributes = parseAttributes(req.Params, "Attribute")
+	if len(Attributes) == 0 {
+		return resp.Error("MissingParameter", "The request must contain the parameter Attribute.Name.")
+	}
+	if _, ok := Attributes["FifoQueue"]; ok {
+		return resp.Error("InvalidAttributeName", "Attribute FifoQueue can only be set on queue creation.")
+	}
+	var AttributesOk, ErrorCode, ErrorMessage = validateQueueAttributes(Attributes)
+	if !AttributesOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+	// FifoQueue could not be changed after creation, unlike other attributes it is safe to read outside of the actor
+	AttributesOk, ErrorCode, ErrorMessage = validateFifoQueueAttributes(Queue.FifoQueue, Attributes)
+	if !AttributesOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+	AttributesOk, ErrorCode, ErrorMessage = validateRedrivePolicy(Queues, Queue.QueueArn, Queue.FifoQueue, Attributes)
+	if !AttributesOk {
 		return resp.Error(ErrorCode, ErrorMessage)
 	}
-	var Result = fmt.Sprintf(`<MD5OfMessageBody>%s</MD5OfMessageBody>
-	<MD5OfMessageAttributes>%s</MD5OfMessageAttributes>
-	<MessageId>%s</MessageId>`, Message.MD5OfMessageBody, Message.MD5OfMessageAttributes, Message.MessageID)
+
+	var ReturnChan = make(chan events.SetAttributesResponseEvent)
+	select {
+	case Queue.SetAttributesChannel <- events.SetAttributesRequestEvent{
+		Attributes: Attributes,
+		ReturnChan: ReturnChan,
+	}:
+	case <-Queue.Deleted:
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+	<-ReturnChan
+
+	return resp.Success("SetQueueAttributes", nil)
+}
+
+// SendMessage TODO: add comment
+func SendMessage(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Message, SendOk, ErrorCode, ErrorMessage = sendMessage(Queue, req, "")
+	if !SendOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+	var Result = server.SendMessageResult{
+		MD5OfMessageBody:             Message.MD5OfMessageBody,
+		MD5OfMessageAttributes:       Message.MD5OfMessageAttributes,
+		MD5OfMessageSystemAttributes: Message.MD5OfMessageSystemAttributes,
+		MessageID:                    Message.MessageID,
+		SequenceNumber:               Message.SequenceNumber,
+	}
 
 	return resp.Success("SendMessage", Result)
 }
 
 // SendMessageBatch TODO: add comment
-func SendMessageBatch(req server.Request, resp server.Response, Queues *sync.Map) (string, int) {
-	var QueueURL = req.Params.Get("QueueUrl")
-	var QueuePtr, ok = Queues.Load(QueueURL)
-	if !ok {
+func SendMessageBatch(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
 		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
 	}
-	var Queue = QueuePtr.(*queue.Queue)
 
 	var BatchValidationResult = validateBatch(req.Params, "SendMessageBatchRequestEntry", []string{
 		"Id",
@@ -408,32 +1346,88 @@ func SendMessageBatch(req server.Request, resp server.Response, Queues *sync.Map
 		return resp.Error(BatchValidationResult.ErrorCode, BatchValidationResult.ErrorMessage)
 	}
 
-	var SuccessfulResult = ""
-	var ErrorResult = ""
+	var Result = server.SendMessageBatchResult{
+		Successful: []server.SendMessageBatchResultEntry{},
+		Failed:     []server.BatchResultErrorEntry{},
+	}
 	for i := 1; i <= BatchValidationResult.BatchSize; i++ {
 		var BatchEntryID = req.Params.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i))
-		var MessageBody = req.Params.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.MessageBody", i))
 
-		var DelaySeconds = Queue.DelaySeconds
-		var RawDelaySeconds = req.Params.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.DelaySeconds", i))
-		if RawDelaySeconds != "" {
-			var err error
-			DelaySeconds, err = strconv.Atoi(RawDelaySeconds)
-			if err != nil {
-				ErrorResult += fmt.Sprintf("<BatchResultErrorEntry><Id>%s</Id><Code>InvalidParameterValue</Code><Message>Parameter DelaySeconds should be of type Integer</Message><SenderFault>true</SenderFault></BatchResultErrorEntry>", BatchEntryID)
-				continue
-			}
-		}
-
-		var Message, ok, ErrorCode, ErrorMessage = sendMessage(Queue, MessageBody, DelaySeconds)
+		var Message, ok, ErrorCode, ErrorMessage = sendMessage(Queue, req, fmt.Sprintf("SendMessageBatchRequestEntry.%d.", i))
 		if ok {
-			SuccessfulResult += fmt.Sprintf("<SendMessageBatchResultEntry><Id>%s</Id><MD5OfMessageAttributes>%s</MD5OfMessageAttributes><MD5OfMessageBody>%s</MD5OfMessageBody><MessageId>%s</MessageId></SendMessageBatchResultEntry>", BatchEntryID, Message.MD5OfMessageBody, Message.MD5OfMessageAttributes, Message.MessageID)
+			Result.Successful = append(Result.Successful, server.SendMessageBatchResultEntry{
+				ID:                           BatchEntryID,
+				MD5OfMessageBody:             Message.MD5OfMessageBody,
+				MD5OfMessageAttributes:       Message.MD5OfMessageAttributes,
+				MD5OfMessageSystemAttributes: Message.MD5OfMessageSystemAttributes,
+				MessageID:                    Message.MessageID,
+				SequenceNumber:               Message.SequenceNumber,
+			})
 		} else {
-			ErrorResult += fmt.Sprintf("<BatchResultErrorEntry><Id>%s</Id><Code>%s</Code><Message>%s</Message><SenderFault>true</SenderFault></BatchResultErrorEntry>", BatchEntryID, ErrorCode, ErrorMessage)
+			Result.Failed = append(Result.Failed, server.BatchResultErrorEntry{
+				ID:          BatchEntryID,
+				Code:        ErrorCode,
+				Message:     ErrorMessage,
+				SenderFault: true,
+			})
 		}
 	}
 
-	var Result = SuccessfulResult + ErrorResult
-
 	return resp.Success("SendMessageBatch", Result)
 }
+
+// TagQueue adds tags to a queue or overwrites values of existing ones.
+func TagQueue(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var Tags = parseTags(req.Params)
+	if len(Tags) == 0 {
+		return resp.Error("MissingParameter", "The request must contain the parameter Tags.")
+	}
+	var TagsOk, ErrorCode, ErrorMessage = validateTags(Tags)
+	if !TagsOk {
+		return resp.Error(ErrorCode, ErrorMessage)
+	}
+
+	var TagResponseEvent = tagQueue(Queue, Tags, nil)
+	if !TagResponseEvent.Ok {
+		return resp.Error(TagResponseEvent.ErrorCode, TagResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("TagQueue", nil)
+}
+
+// UntagQueue removes tags with given keys from a queue, missing keys are ignored.
+func UntagQueue(req server.Request, resp server.Response, Queues *queuemgr.Manager) (string, int) {
+	var Queue = Queues.GetByURL(req.AccountID, req.Region, req.Params.Get("QueueUrl"))
+	if Queue == nil {
+		return resp.Error("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+
+	var TagKeys []string
+	for i := 1; ; i++ {
+		var TagKey, ok = req.Params[fmt.Sprintf("TagKey.%d", i)]
+		if !ok {
+			break
+		}
+		TagKeys = append(TagKeys, TagKey[0])
+	}
+	if len(TagKeys) == 0 {
+		return resp.Error("MissingParameter", "The request must contain the parameter TagKeys.")
+	}
+	for _, TagKey := range TagKeys {
+		if ok, ErrorCode, ErrorMessage := validateTagKey(TagKey); !ok {
+			return resp.Error(ErrorCode, ErrorMessage)
+		}
+	}
+
+	var TagResponseEvent = tagQueue(Queue, nil, TagKeys)
+	if !TagResponseEvent.Ok {
+		return resp.Error(TagResponseEvent.ErrorCode, TagResponseEvent.ErrorMessage)
+	}
+
+	return resp.Success("UntagQueue", nil)
+}
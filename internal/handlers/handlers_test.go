@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseMessageAttributesRejectsInvalidCharacters(t *testing.T) {
+	for _, StringValue := range []string{"a\x00b\x01", "a\x1fb", "a\xffb", "a\uFFFEb"} {
+		var Parameters = url.Values{
+			"MessageAttribute.1.Name":              {"text"},
+			"MessageAttribute.1.Value.DataType":    {"String.custom"},
+			"MessageAttribute.1.Value.StringValue": {StringValue},
+		}
+		var _, ok, ErrorCode, _ = parseMessageAttributes(Parameters, "MessageAttribute")
+		if ok || ErrorCode != "InvalidParameterValue" {
+			t.Fatalf("expected value %q to be rejected, got %t %s", StringValue, ok, ErrorCode)
+		}
+	}
+
+	var Parameters = url.Values{
+		"MessageAttribute.1.Name":              {"text"},
+		"MessageAttribute.1.Value.DataType":    {"String"},
+		"MessageAttribute.1.Value.StringValue": {"tab\t and \U0001F600"},
+	}
+	if Attributes, ok, _, ErrorMessage := parseMessageAttributes(Parameters, "MessageAttribute"); !ok || Attributes["text"].StringValue != "tab\t and \U0001F600" {
+		t.Fatalf("expected valid value to be accepted, got %s", ErrorMessage)
+	}
+}
@@ -1,6 +1,97 @@
 //Package limits defines various hard limits in SQS.
 package limits
 
+import "time"
+
 // MaxBatchSize defines what could be the maximum size of the batch in SQS.
 // It drives constraints in receive functions and in validators.
 const MaxBatchSize = 10
+
+// MaxVisibilityTimeout defines the maximum visibility timeout of a message in seconds (12 hours).
+const MaxVisibilityTimeout = 43200
+
+// PurgeQueueInterval defines how often in seconds a queue could be purged.
+const PurgeQueueInterval = 60
+
+// DeletedQueueNameReuseInterval defines for how long in seconds a name of a deleted queue could not be used
+// to create a new queue.
+const DeletedQueueNameReuseInterval = 60
+
+// MaxDelaySeconds defines the maximum delay of a message in seconds (15 minutes).
+const MaxDelaySeconds = 900
+
+// MinMaximumMessageSize and MaxMaximumMessageSize define the bounds of the MaximumMessageSize queue attribute in bytes.
+const (
+	MinMaximumMessageSize = 1024
+	MaxMaximumMessageSize = 262144
+)
+
+// MinMessageRetentionPeriod and MaxMessageRetentionPeriod define the bounds of the MessageRetentionPeriod
+// queue attribute in seconds (1 minute to 14 days).
+const (
+	MinMessageRetentionPeriod = 60
+	MaxMessageRetentionPeriod = 1209600
+)
+
+// MinKmsDataKeyReusePeriodSeconds and MaxKmsDataKeyReusePeriodSeconds define the bounds of the
+// KmsDataKeyReusePeriodSeconds queue attribute in seconds (1 minute to 24 hours).
+const (
+	MinKmsDataKeyReusePeriodSeconds = 60
+	MaxKmsDataKeyReusePeriodSeconds = 86400
+)
+
+// RetentionCheckInterval defines how often messages older than the queue retention period are removed.
+const RetentionCheckInterval = time.Second
+
+// MaxReceiveMessageWaitTimeSeconds defines the maximum time in seconds a receive request could wait for messages.
+const MaxReceiveMessageWaitTimeSeconds = 20
+
+// ReceiveWaitersCheckInterval defines how often queue checks if parked receive requests
+// could be fulfilled with messages that became visible, or should be completed empty.
+const ReceiveWaitersCheckInterval = 100 * time.Millisecond
+
+// MaxListQueuesResults defines how many queues ListQueues returns at most.
+const MaxListQueuesResults = 1000
+
+// MaxMaxReceiveCount defines the maximum value of maxReceiveCount in a RedrivePolicy.
+const MaxMaxReceiveCount = 1000
+
+// MaxRedriveSourceQueueArns defines how many source queues could be listed in a RedriveAllowPolicy.
+const MaxRedriveSourceQueueArns = 10
+
+// DeduplicationInterval defines for how long in seconds FIFO queue deduplicates messages.
+const DeduplicationInterval = 300
+
+// DeduplicationCleanupInterval defines how often expired deduplication entries are removed from FIFO queues.
+const DeduplicationCleanupInterval = time.Minute
+
+// MaxPermissionLabelLength defines the maximum length of a permission label in AddPermission.
+const MaxPermissionLabelLength = 80
+
+// MaxPermissionActions and MaxPermissionPrincipals define how many actions and accounts
+// a single AddPermission request could grant.
+const (
+	MaxPermissionActions    = 7
+	MaxPermissionPrincipals = 7
+)
+
+// MaxClockSkew defines how much time of a signed request could differ from server time.
+const MaxClockSkew = 15 * time.Minute
+
+// MaxPresignedExpires defines the maximum lifetime of a presigned request in seconds (7 days).
+const MaxPresignedExpires = 604800
+
+// MaxQueueTags defines how many tags a queue could have.
+const MaxQueueTags = 50
+
+// MaxTagKeyLength and MaxTagValueLength define the maximum length of a queue tag key and value.
+const (
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// MaxMessageAttributes defines how many message attributes a message could have.
+const MaxMessageAttributes = 10
+
+// MaxMessageAttributeNameLength defines the maximum length of a message attribute name.
+const MaxMessageAttributeNameLength = 256
@@ -0,0 +1,9 @@
+package queue
+
+// MessageAttributeValue represents a value of a message attribute.
+// DataType is one of String, Number or Binary, optionally followed by a custom type suffix, e.g. Number.int.
+type MessageAttributeValue struct {
+	DataType    string
+	StringValue string
+	BinaryValue []byte
+}
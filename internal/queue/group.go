@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// MessageGroups keeps messages of a FIFO queue in their message groups in order of sequence numbers,
+// and groups whose first message is visible ordered by sequence numbers of their first messages.
+// Messages of a group are received in order, so only groups from that index could be received from.
+// Zero value is not usable, use NewMessageGroups.
+type MessageGroups struct {
+	visible *MessageHeap
+	groups  map[string]*messageGroup
+	ready   groupHeap
+}
+
+type messageGroup struct {
+	messages   *list.List
+	readyIndex int
+}
+
+// NewMessageGroups creates an empty index of message groups, a message is considered visible
+// if it is in VisibleMessages, which should be a heap of visible messages of the same queue.
+func NewMessageGroups(VisibleMessages *MessageHeap) *MessageGroups {
+	return &MessageGroups{
+		visible: VisibleMessages,
+		groups:  make(map[string]*messageGroup),
+	}
+}
+
+// Push appends a message to its group, its sequence number should be greater than ones of messages in the group.
+func (Groups *MessageGroups) Push(Message *Message) {
+	var Group, ok = Groups.groups[Message.MessageGroupID]
+	if !ok {
+		Group = &messageGroup{messages: list.New(), readyIndex: -1}
+		Groups.groups[Message.MessageGroupID] = Group
+	}
+	Message.groupElement = Group.messages.PushBack(Message)
+	Groups.update(Group)
+}
+
+// Remove removes a message from its group, it does nothing if the message is not in a group.
+func (Groups *MessageGroups) Remove(Message *Message) {
+	var Group, ok = Groups.groups[Message.MessageGroupID]
+	if !ok || Message.groupElement == nil {
+		return
+	}
+
+	Group.messages.Remove(Message.groupElement)
+	Message.groupElement = nil
+	if Group.messages.Len() == 0 {
+		if Group.readyIndex >= 0 {
+			heap.Remove(&Groups.ready, Group.readyIndex)
+		}
+		delete(Groups.groups, Message.MessageGroupID)
+		return
+	}
+	Groups.update(Group)
+}
+
+// Update should be called after a message is added to or removed from the visible heap,
+// so that its group is added to or removed from ready groups if it is the first message of the group.
+func (Groups *MessageGroups) Update(Message *Message) {
+	if Group, ok := Groups.groups[Message.MessageGroupID]; ok {
+		Groups.update(Group)
+	}
+}
+
+// PeekReady returns the first message of a ready group whose first message has the lowest sequence number,
+// or nil if no group is ready.
+func (Groups *MessageGroups) PeekReady() *Message {
+	if len(Groups.ready) == 0 {
+		return nil
+	}
+
+	return Groups.ready[0].first()
+}
+
+// Next returns a message which follows the given one in its group, or nil if it is the last one.
+func (Groups *MessageGroups) Next(Message *Message) *Message {
+	if Message.groupElement == nil {
+		return nil
+	}
+
+	return messageOf(Message.groupElement.Next())
+}
+
+func (Groups *MessageGroups) update(Group *messageGroup) {
+	var IsReady = Groups.visible.Contains(Group.first())
+	switch {
+	case IsReady && Group.readyIndex < 0:
+		heap.Push(&Groups.ready, Group)
+	case IsReady:
+		// First message could have been replaced by the next one
+		heap.Fix(&Groups.ready, Group.readyIndex)
+	case Group.readyIndex >= 0:
+		heap.Remove(&Groups.ready, Group.readyIndex)
+	}
+}
+
+func (Group *messageGroup) first() *Message {
+	return messageOf(Group.messages.Front())
+}
+
+func messageOf(Element *list.Element) *Message {
+	if Element == nil {
+		return nil
+	}
+
+	return Element.Value.(*Message)
+}
+
+// groupHeap implements heap.Interface for ready groups.
+type groupHeap []*messageGroup
+
+func (h groupHeap) Len() int { return len(h) }
+
+func (h groupHeap) Less(i, j int) bool {
+	return h[i].first().SequenceNumber < h[j].first().SequenceNumber
+}
+
+func (h groupHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].readyIndex = i
+	h[j].readyIndex = j
+}
+
+func (h *groupHeap) Push(x interface{}) {
+	var Group = x.(*messageGroup)
+	Group.readyIndex = len(*h)
+	*h = append(*h, Group)
+}
+
+func (h *groupHeap) Pop() interface{} {
+	var Last = len(*h) - 1
+	var Group = (*h)[Last]
+	(*h)[Last] = nil
+	*h = (*h)[:Last]
+	Group.readyIndex = -1
+
+	return Group
+}
@@ -0,0 +1,104 @@
+package queue
+
+import "container/heap"
+
+// MessageHeap is a min-heap of messages which keeps positions of messages inside of them,
+// so that any message could be removed in O(log n).
+// Zero value is not usable, use one of the constructors.
+type MessageHeap struct {
+	messages messageHeap
+}
+
+// NewVisibleHeap creates a heap of visible messages ordered by their sent timestamps.
+// A message could be either in a visible heap or in an invisible heap, but not in both.
+func NewVisibleHeap() *MessageHeap {
+	return &MessageHeap{messageHeap{
+		less:  func(a, b *Message) bool { return a.SentTimestamp < b.SentTimestamp },
+		index: func(Message *Message) *int { return &Message.visibilityIndex },
+	}}
+}
+
+// NewInvisibleHeap creates a heap of delayed and in flight messages ordered by their visibility deadlines,
+// messages to become visible first are at the top of it.
+func NewInvisibleHeap() *MessageHeap {
+	return &MessageHeap{messageHeap{
+		less:  func(a, b *Message) bool { return a.VisibilityDeadline < b.VisibilityDeadline },
+		index: func(Message *Message) *int { return &Message.visibilityIndex },
+	}}
+}
+
+// NewRetentionHeap creates a heap of messages ordered by their sent timestamps,
+// messages to be expired first are at the top of it.
+func NewRetentionHeap() *MessageHeap {
+	return &MessageHeap{messageHeap{
+		less:  func(a, b *Message) bool { return a.SentTimestamp < b.SentTimestamp },
+		index: func(Message *Message) *int { return &Message.retentionIndex },
+	}}
+}
+
+// Len returns a number of messages in the heap.
+func (Heap *MessageHeap) Len() int {
+	return Heap.messages.Len()
+}
+
+// Peek returns a message at the top of the heap without removing it, or nil if the heap is empty.
+func (Heap *MessageHeap) Peek() *Message {
+	if Heap.messages.Len() == 0 {
+		return nil
+	}
+
+	return Heap.messages.items[0]
+}
+
+// Push adds a message to the heap.
+func (Heap *MessageHeap) Push(Message *Message) {
+	heap.Push(&Heap.messages, Message)
+}
+
+// Contains checks whether a message is in the heap.
+func (Heap *MessageHeap) Contains(Message *Message) bool {
+	var Index = *Heap.messages.index(Message)
+	return Index >= 0 && Index < Heap.messages.Len() && Heap.messages.items[Index] == Message
+}
+
+// Remove removes a message from the heap, it does nothing if the message is not in the heap.
+func (Heap *MessageHeap) Remove(Message *Message) {
+	if !Heap.Contains(Message) {
+		return
+	}
+
+	heap.Remove(&Heap.messages, *Heap.messages.index(Message))
+}
+
+// messageHeap implements heap.Interface, index points to a field of a message keeping its position.
+type messageHeap struct {
+	items []*Message
+	less  func(a, b *Message) bool
+	index func(Message *Message) *int
+}
+
+func (h messageHeap) Len() int { return len(h.items) }
+
+func (h messageHeap) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+
+func (h messageHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	*h.index(h.items[i]) = i
+	*h.index(h.items[j]) = j
+}
+
+func (h *messageHeap) Push(x interface{}) {
+	var Message = x.(*Message)
+	*h.index(Message) = len(h.items)
+	h.items = append(h.items, Message)
+}
+
+func (h *messageHeap) Pop() interface{} {
+	var Last = len(h.items) - 1
+	var Message = h.items[Last]
+	h.items[Last] = nil
+	h.items = h.items[:Last]
+	*h.index(Message) = -1
+
+	return Message
+}
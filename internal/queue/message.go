@@ -1,15 +1,28 @@
 package queue
 
+import "container/list"
+
 // Message TODO: add comment
+// SentTimestamp and ApproximateFirstReceiveTimestamp are in milliseconds, VisibilityDeadline is in seconds.
 type Message struct {
 	MessageID                        string
 	Body                             string
 	MD5OfMessageBody                 string
 	MD5OfMessageAttributes           string
+	MD5OfMessageSystemAttributes     string
+	MessageAttributes                map[string]MessageAttributeValue
 	SenderID                         string
 	ReceiptHandle                    string
 	ApproximateFirstReceiveTimestamp int64
 	ApproximateReceiveCount          int
 	SentTimestamp                    int64
 	VisibilityDeadline               int64
+	DeadLetterQueueSourceArn         string
+	MessageGroupID                   string
+	MessageDeduplicationID           string
+	SequenceNumber                   string
+	AWSTraceHeader                   string
+	visibilityIndex                  int
+	retentionIndex                   int
+	groupElement                     *list.Element
 }
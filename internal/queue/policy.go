@@ -0,0 +1,59 @@
+package queue
+
+import "encoding/json"
+
+// Policy represents an IAM-style Policy attribute of a queue which defines
+// who could access the queue.
+type Policy struct {
+	Version   string            `json:"Version,omitempty"`
+	ID        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// PolicyStatement represents a single statement of a Policy.
+type PolicyStatement struct {
+	Sid       string                             `json:"Sid,omitempty"`
+	Effect    string                             `json:"Effect"`
+	Principal PolicyPrincipal                    `json:"Principal,omitempty"`
+	Action    PolicyValues                       `json:"Action"`
+	Resource  PolicyValues                       `json:"Resource,omitempty"`
+	Condition map[string]map[string]PolicyValues `json:"Condition,omitempty"`
+}
+
+// PolicyValues represents a policy element which could be either a single string or a list of strings.
+type PolicyValues []string
+
+// MarshalJSON implements json.Marshaler.
+func (Values PolicyValues) MarshalJSON() ([]byte, error) {
+	if len(Values) == 1 {
+		return json.Marshal(Values[0])
+	}
+
+	return json.Marshal([]string(Values))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (Values *PolicyValues) UnmarshalJSON(Data []byte) error {
+	var Value string
+	if err := json.Unmarshal(Data, &Value); err == nil {
+		*Values = PolicyValues{Value}
+		return nil
+	}
+
+	return json.Unmarshal(Data, (*[]string)(Values))
+}
+
+// PolicyPrincipal represents principals of a policy statement keyed by their type, e.g. AWS.
+// Principal "*" is equivalent to {"AWS": "*"} and is represented that way.
+type PolicyPrincipal map[string]PolicyValues
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (Principal *PolicyPrincipal) UnmarshalJSON(Data []byte) error {
+	var Value string
+	if err := json.Unmarshal(Data, &Value); err == nil {
+		*Principal = PolicyPrincipal{"AWS": PolicyValues{Value}}
+		return nil
+	}
+
+	return json.Unmarshal(Data, (*map[string]PolicyValues)(Principal))
+}
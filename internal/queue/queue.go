@@ -2,6 +2,7 @@ package queue
 
 import (
 	"sync"
+	"time"
 
 	"github.com/andreyst/go-sqs/internal/events"
 )
@@ -10,20 +11,64 @@ import (
 type Queue struct {
 	QueueName                             string
 	QueueArn                              string
+	AccountID                             string
+	Region                                string
 	ApproximateNumberOfMessages           int64
 	ApproximateNumberOfMessagesNotVisible int64
 	ApproximateNumberOfMessagesDelayed    int64
 	CreatedTimestamp                      int64
 	LastModifiedTimestamp                 int64
+	LastPurgedTimestamp                   int64
 	VisibilityTimeout                     int
 	MaximumMessageSize                    int
 	MessageRetentionPeriod                int
 	DelaySeconds                          int
 	ReceiveMessageWaitTimeSeconds         int
+	RedrivePolicy                         *RedrivePolicy
+	RedriveAllowPolicy                    *RedriveAllowPolicy
+	Policy                                *Policy
+	FifoQueue                             bool
+	ContentBasedDeduplication             bool
+	DeduplicationScope                    string
+	FifoThroughputLimit                   string
+	KmsMasterKeyID                        string
+	KmsDataKeyReusePeriodSeconds          int
+	SqsManagedSseEnabled                  bool
+	LastSequenceNumber                    uint64
+	DeduplicationEntries                  map[string]DeduplicationEntry
+	Tags                                  map[string]string
 	Messages                              sync.Map
 	Messages2                             map[string]*Message
-	SendChannel                           chan *Message
+	VisibleMessages                       *MessageHeap
+	InvisibleMessages                     *MessageHeap
+	MessagesBySentTimestamp               *MessageHeap
+	MessageGroups                         *MessageGroups
+	SendChannel                           chan events.SendRequestEvent
+	RedriveChannel                        chan events.RedriveRequestEvent
 	ReceiveChannel                        chan events.ReceiveRequestEvent
 	DeleteChannel                         chan events.DeleteRequestEvent
+	ChangeVisibilityChannel               chan events.ChangeVisibilityRequestEvent
+	PurgeChannel                          chan events.PurgeRequestEvent
+	GetAttributesChannel                  chan events.GetAttributesRequestEvent
+	SetAttributesChannel                  chan events.SetAttributesRequestEvent
+	TagChannel                            chan events.TagRequestEvent
+	PermissionChannel                     chan events.PermissionRequestEvent
+	Deleted                               chan struct{}
 	ReceiptHandles                        sync.Map
+	ReceiveWaiters                        []ReceiveWaiter
+}
+
+// ReceiveWaiter represents a receive request parked by the queue actor until
+// messages become available or WaitDeadline passes.
+type ReceiveWaiter struct {
+	Event        events.ReceiveRequestEvent
+	WaitDeadline time.Time
+}
+
+// DeduplicationEntry represents a message sent to a FIFO queue, which is remembered
+// to deduplicate messages with the same deduplication ID until ExpiresAt.
+type DeduplicationEntry struct {
+	MessageID      string
+	SequenceNumber string
+	ExpiresAt      int64
 }
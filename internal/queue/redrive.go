@@ -0,0 +1,15 @@
+package queue
+
+// RedrivePolicy represents a RedrivePolicy attribute of a queue which defines
+// where and when messages are moved after failing to be processed.
+type RedrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     int    `json:"maxReceiveCount"`
+}
+
+// RedriveAllowPolicy represents a RedriveAllowPolicy attribute of a queue which defines
+// which source queues could use the queue as a dead-letter queue.
+type RedriveAllowPolicy struct {
+	RedrivePermission string   `json:"redrivePermission"`
+	SourceQueueArns   []string `json:"sourceQueueArns,omitempty"`
+}
@@ -1 +1,160 @@
+// Package queuemgr keeps track of existing queues.
 package queuemgr
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/limits"
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+// Manager owns queues of all accounts and regions indexed by their ARNs,
+// which include account, region and name of a queue. It is safe for concurrent use.
+// deleted keeps deletion times of recently deleted queues, whose names could not be reused yet,
+// deletions lists them in order of deletion so that expired entries are pruned from its head.
+type Manager struct {
+	mutex     sync.RWMutex
+	queues    map[string]*queue.Queue
+	deleted   map[string]time.Time
+	deletions []deletion
+}
+
+type deletion struct {
+	QueueArn  string
+	DeletedAt time.Time
+}
+
+// New creates an empty queue manager.
+func New() *Manager {
+	return &Manager{
+		queues:  make(map[string]*queue.Queue),
+		deleted: make(map[string]time.Time),
+	}
+}
+
+// QueueArn returns an ARN of a queue.
+func QueueArn(Region string, AccountID string, QueueName string) string {
+	return fmt.Sprintf("arn:aws:sqs:%s:%s:%s", Region, AccountID, QueueName)
+}
+
+// QueueURL returns a URL of a queue, BaseURL is a scheme and a host without trailing slash.
+func QueueURL(BaseURL string, Queue *queue.Queue) string {
+	return fmt.Sprintf("%s/%s/%s", BaseURL, Queue.AccountID, Queue.QueueName)
+}
+
+// Create adds a queue unless a queue with the same ARN exists.
+// It returns the queue which ends up in the manager and whether it is the given one.
+// Nil queue is returned if a queue with the same ARN was deleted recently.
+func (Manager *Manager) Create(Queue *queue.Queue) (*queue.Queue, bool) {
+	Manager.mutex.Lock()
+	defer Manager.mutex.Unlock()
+
+	if ExistingQueue, ok := Manager.queues[Queue.QueueArn]; ok {
+		return ExistingQueue, false
+	}
+	Manager.pruneDeleted(time.Now())
+	if _, ok := Manager.deleted[Queue.QueueArn]; ok {
+		return nil, false
+	}
+	Manager.queues[Queue.QueueArn] = Queue
+
+	return Queue, true
+}
+
+// Delete removes a queue and closes its Deleted channel, which stops the queue actor
+// and fails requests waiting for it. It returns false if the queue is already gone.
+func (Manager *Manager) Delete(Queue *queue.Queue) bool {
+	Manager.mutex.Lock()
+	defer Manager.mutex.Unlock()
+
+	if Manager.queues[Queue.QueueArn] != Queue {
+		return false
+	}
+	delete(Manager.queues, Queue.QueueArn)
+	var Now = time.Now()
+	Manager.pruneDeleted(Now)
+	Manager.deleted[Queue.QueueArn] = Now
+	Manager.deletions = append(Manager.deletions, deletion{QueueArn: Queue.QueueArn, DeletedAt: Now})
+	close(Queue.Deleted)
+
+	return true
+}
+
+// pruneDeleted forgets queues deleted longer than the name reuse interval ago.
+// A queue could be recreated and deleted again, so only its latest deletion is forgotten with the map entry.
+func (Manager *Manager) pruneDeleted(Now time.Time) {
+	var Expired = 0
+	for Expired < len(Manager.deletions) && Now.Sub(Manager.deletions[Expired].DeletedAt) >= limits.DeletedQueueNameReuseInterval*time.Second {
+		var Deletion = Manager.deletions[Expired]
+		if Manager.deleted[Deletion.QueueArn].Equal(Deletion.DeletedAt) {
+			delete(Manager.deleted, Deletion.QueueArn)
+		}
+		Expired++
+	}
+	Manager.deletions = Manager.deletions[Expired:]
+}
+
+// GetByArn returns a queue by its ARN or nil if there is no such queue.
+func (Manager *Manager) GetByArn(QueueArn string) *queue.Queue {
+	Manager.mutex.RLock()
+	defer Manager.mutex.RUnlock()
+
+	return Manager.queues[QueueArn]
+}
+
+// GetByName returns a queue of given account in given region.
+func (Manager *Manager) GetByName(AccountID string, Region string, QueueName string) *queue.Queue {
+	return Manager.GetByArn(QueueArn(Region, AccountID, QueueName))
+}
+
+// GetByURL returns a queue by its URL regardless of URL host. Both http://host/123456789012/name
+// and legacy http://host/name forms are accepted, the latter refers to a queue of AccountID.
+// Region is a region of the caller, since URL host may not contain one.
+func (Manager *Manager) GetByURL(AccountID string, Region string, QueueURL string) *queue.Queue {
+	var ParsedURL, err = url.Parse(QueueURL)
+	if err != nil {
+		return nil
+	}
+
+	var PathParts = strings.Split(strings.Trim(ParsedURL.Path, "/"), "/")
+	switch len(PathParts) {
+	case 1:
+		return Manager.GetByName(AccountID, Region, PathParts[0])
+	case 2:
+		return Manager.GetByName(PathParts[0], Region, PathParts[1])
+	}
+
+	return nil
+}
+
+// List returns queues of given account in given region with names starting with QueueNamePrefix, sorted by name.
+func (Manager *Manager) List(AccountID string, Region string, QueueNamePrefix string) []*queue.Queue {
+	var Queues = Manager.Filter(func(Queue *queue.Queue) bool {
+		return Queue.AccountID == AccountID && Queue.Region == Region && strings.HasPrefix(Queue.QueueName, QueueNamePrefix)
+	})
+	sort.Slice(Queues, func(i, j int) bool {
+		return Queues[i].QueueName < Queues[j].QueueName
+	})
+
+	return Queues
+}
+
+// Filter returns queues of all accounts and regions matching a predicate, in no particular order.
+func (Manager *Manager) Filter(Predicate func(Queue *queue.Queue) bool) []*queue.Queue {
+	Manager.mutex.RLock()
+	defer Manager.mutex.RUnlock()
+
+	var Queues []*queue.Queue
+	for _, Queue := range Manager.queues {
+		if Predicate(Queue) {
+			Queues = append(Queues, Queue)
+		}
+	}
+
+	return Queues
+}
@@ -0,0 +1,43 @@
+package queuemgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/limits"
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+func newTestQueue(QueueName string) *queue.Queue {
+	return &queue.Queue{
+		QueueName: QueueName,
+		QueueArn:  QueueArn("us-east-1", "000000000000", QueueName),
+		AccountID: "000000000000",
+		Region:    "us-east-1",
+		Deleted:   make(chan struct{}),
+	}
+}
+
+func TestDeletedQueuesArePruned(t *testing.T) {
+	var Manager = New()
+	for _, QueueName := range []string{"first", "second"} {
+		var Queue = newTestQueue(QueueName)
+		Manager.Create(Queue)
+		Manager.Delete(Queue)
+	}
+
+	if Queue, _ := Manager.Create(newTestQueue("first")); Queue != nil {
+		t.Fatal("expected recently deleted queue name not to be reused")
+	}
+	if len(Manager.deleted) != 2 || len(Manager.deletions) != 2 {
+		t.Fatalf("expected 2 recently deleted queues, got %d", len(Manager.deleted))
+	}
+
+	Manager.pruneDeleted(time.Now().Add(limits.DeletedQueueNameReuseInterval * time.Second))
+	if len(Manager.deleted) != 0 || len(Manager.deletions) != 0 {
+		t.Fatalf("expected deleted queues to be forgotten after reuse interval, got %d", len(Manager.deleted))
+	}
+	if Queue, ok := Manager.Create(newTestQueue("second")); Queue == nil || !ok {
+		t.Fatal("expected queue name to be reused after reuse interval")
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Protocol represents a wire protocol used by a client.
+type Protocol int
+
+const (
+	// QueryProtocol is a protocol with form-encoded requests and XML responses.
+	QueryProtocol Protocol = iota
+	// JSONProtocol is a protocol with JSON requests and responses, used by newer SDKs.
+	JSONProtocol
+)
+
+// JSONContentType is a content type of requests and responses in JSON protocol.
+const JSONContentType = "application/x-amz-json-1.0"
+
+// jsonTargetPrefix is a prefix of X-Amz-Target header value which precedes action name.
+const jsonTargetPrefix = "AmazonSQS."
+
+// jsonListMembers maps names of JSON lists to names of their members in query protocol.
+var jsonListMembers = map[string]string{
+	"AWSAccountIds":               "AWSAccountId",
+	"Actions":                     "ActionName",
+	"AttributeNames":              "AttributeName",
+	"MessageAttributeNames":       "MessageAttributeName",
+	"MessageSystemAttributeNames": "MessageSystemAttributeName",
+	"TagKeys":                     "TagKey",
+}
+
+// jsonMapMembers maps names of JSON objects to names of their entries in query protocol
+// along with names of entry key and value.
+var jsonMapMembers = map[string][3]string{
+	"Attributes":              {"Attribute", "Name", "Value"},
+	"MessageAttributes":       {"MessageAttribute", "Name", "Value"},
+	"MessageSystemAttributes": {"MessageSystemAttribute", "Name", "Value"},
+	"Tags":                    {"Tag", "Key", "Value"},
+	// CreateQueue names its tags in lower case
+	"tags": {"Tag", "Key", "Value"},
+}
+
+// ProtocolOf returns a protocol used by a request.
+func ProtocolOf(r *http.Request) Protocol {
+	if r.Header.Get("X-Amz-Target") != "" || strings.HasPrefix(r.Header.Get("Content-Type"), JSONContentType) {
+		return JSONProtocol
+	}
+
+	return QueryProtocol
+}
+
+// ParseJSONRequest returns action and parameters of a JSON protocol request.
+// Parameters are flattened to the same form as in query protocol, so handlers do not depend on protocol.
+func ParseJSONRequest(r *http.Request) (string, url.Values, error) {
+	var Action = strings.TrimPrefix(r.Header.Get("X-Amz-Target"), jsonTargetPrefix)
+
+	var Buffer bytes.Buffer
+	if _, err := Buffer.ReadFrom(r.Body); err != nil {
+		return Action, nil, err
+	}
+
+	var Params = url.Values{}
+	if len(bytes.TrimSpace(Buffer.Bytes())) == 0 {
+		return Action, Params, nil
+	}
+
+	var Decoder = json.NewDecoder(&Buffer)
+	Decoder.UseNumber()
+	var Body map[string]interface{}
+	if err := Decoder.Decode(&Body); err != nil {
+		return Action, nil, err
+	}
+
+	if err := flattenJSON(Params, "", Body, Action+"RequestEntry"); err != nil {
+		return Action, nil, err
+	}
+
+	return Action, Params, nil
+}
+
+func flattenJSON(Params url.Values, Prefix string, Object map[string]interface{}, EntryName string) error {
+	for _, Key := range sortedObjectKeys(Object) {
+		switch Value := Object[Key].(type) {
+		case nil:
+		case map[string]interface{}:
+			var Member, ok = jsonMapMembers[Key]
+			if !ok {
+				if err := flattenJSON(Params, Prefix+Key+".", Value, EntryName); err != nil {
+					return err
+				}
+				continue
+			}
+			for i, EntryKey := range sortedObjectKeys(Value) {
+				var EntryPrefix = fmt.Sprintf("%s%s.%d.", Prefix, Member[0], i+1)
+				Params.Set(EntryPrefix+Member[1], EntryKey)
+				if err := flattenJSONValue(Params, EntryPrefix+Member[2], Value[EntryKey], EntryName); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			var Member, ok = jsonListMembers[Key]
+			if Key == "Entries" {
+				Member, ok = EntryName, true
+			}
+			if !ok {
+				Member = Key + ".member"
+			}
+			for i, Item := range Value {
+				if err := flattenJSONValue(Params, fmt.Sprintf("%s%s.%d", Prefix, Member, i+1), Item, EntryName); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := flattenJSONValue(Params, Prefix+Key, Value, EntryName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func flattenJSONValue(Params url.Values, Name string, Value interface{}, EntryName string) error {
+	switch Value := Value.(type) {
+	case nil:
+	case string:
+		Params.Set(Name, Value)
+	case json.Number:
+		Params.Set(Name, Value.String())
+	case bool:
+		if Value {
+			Params.Set(Name, "true")
+		} else {
+			Params.Set(Name, "false")
+		}
+	case map[string]interface{}:
+		return flattenJSON(Params, Name+".", Value, EntryName)
+	default:
+		return fmt.Errorf("unexpected value of %s", Name)
+	}
+
+	return nil
+}
+
+func sortedObjectKeys(Object map[string]interface{}) []string {
+	var Keys = make([]string, 0, len(Object))
+	for Key := range Object {
+		Keys = append(Keys, Key)
+	}
+	sort.Strings(Keys)
+
+	return Keys
+}
@@ -1,11 +1,23 @@
 package server
 
 import (
+	"context"
 	"net/url"
 )
 
 // Request represents a user request.
+// Context is cancelled when the client goes away.
+// Params of JSON protocol requests are flattened to the query protocol form.
+// AccountID is an account of the caller.
+// BaseURL is a scheme and a host queue URLs are built with, Region is a region of queues.
+// TraceHeader is an X-Ray trace header passed in X-Amzn-Trace-Id HTTP header, if any.
 type Request struct {
-	ID     string
-	Params url.Values
+	ID          string
+	Protocol    Protocol
+	AccountID   string
+	Region      string
+	BaseURL     string
+	TraceHeader string
+	Params      url.Values
+	Context     context.Context
 }
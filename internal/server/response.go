@@ -1,31 +1,154 @@
 package server
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
 
 // Response represents a response to a user request.
+// Header is a header of the underlying HTTP response.
 type Response struct {
-	Req Request
+	Req    Request
+	Header http.Header
+}
+
+// XMLNamespace is a namespace of query protocol responses.
+const XMLNamespace = "http://queue.amazonaws.com/doc/2012-11-05/"
+
+// xmlResponse is an envelope of a successful query protocol response, XMLName is <Action>Response.
+type xmlResponse struct {
+	XMLName          xml.Name
+	Result           xmlResult
+	ResponseMetadata xmlResponseMetadata
+}
+
+// xmlResult is a result of an action which is serialized as an element with the given name.
+type xmlResult struct {
+	Name  string
+	Value interface{}
+}
+
+// MarshalXML implements xml.Marshaler.
+func (Result xmlResult) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(Result.Value, xml.StartElement{Name: xml.Name{Local: Result.Name}})
+}
+
+type xmlResponseMetadata struct {
+	RequestID string `xml:"RequestId"`
+}
+
+// xmlErrorResponse is an envelope of a query protocol error response.
+type xmlErrorResponse struct {
+	XMLName   xml.Name `xml:"http://queue.amazonaws.com/doc/2012-11-05/ ErrorResponse"`
+	Error     xmlError
+	RequestID string `xml:"RequestId"`
+}
+
+type xmlError struct {
+	Type    string
+	Code    string
+	Message string
+	Detail  struct{}
+}
+
+// jsonErrorTypes maps query protocol error codes to error types of JSON protocol which are named differently.
+var jsonErrorTypes = map[string]string{
+	"AWS.SimpleQueueService.NonExistentQueue": "QueueDoesNotExist",
+	"QueueAlreadyExists":                      "QueueNameExists",
 }
 
 // Success generates a successful response.
-func (resp Response) Success(Action string, Result string) (Body string, Code int) {
-	return fmt.Sprintf(`<%sResponse>
-	<%sResult>%s</%sResult>
-	<ResponseMetadata>
-		<RequestId>%s</RequestId>
-	</ResponseMetadata>
-</%sResponse>`, Action, Action, Result, Action, resp.Req.ID, Action), 200
+// Result is serialized according to request protocol, nil Result means that action returns no data.
+func (resp Response) Success(Action string, Result interface{}) (Body string, Code int) {
+	if resp.Req.Protocol == JSONProtocol {
+		resp.setContentType(JSONContentType)
+		if Result == nil {
+			return "{}", 200
+		}
+		var ResultBody, err = json.Marshal(Result)
+		if err != nil {
+			return resp.internalError(err)
+		}
+		return string(ResultBody), 200
+	}
+
+	resp.setContentType("text/xml")
+	if Result == nil {
+		Result = struct{}{}
+	}
+	return resp.xmlBody(xmlResponse{
+		XMLName:          xml.Name{Space: XMLNamespace, Local: Action + "Response"},
+		Result:           xmlResult{Name: Action + "Result", Value: Result},
+		ResponseMetadata: xmlResponseMetadata{RequestID: resp.Req.ID},
+	}, 200)
 }
 
 // Error generates an error response.
 func (resp Response) Error(ErrorCode string, ErrorMessage string) (Body string, Code int) {
-	return fmt.Sprintf(`<ErrorResponse>
-  <Error>
-    <Type>Sender</Type>
-    <Code>%s</Code>
-    <Message>%s</Message>
-    <Detail/>
-  </Error>
-  <RequestId>%s</RequestId>
-</ErrorResponse>`, ErrorCode, ErrorMessage, resp.Req.ID), 400
+	return resp.ErrorWithStatus(ErrorCode, ErrorMessage, 400)
+}
+
+func (resp Response) internalError(err error) (Body string, Code int) {
+	return resp.ErrorWithStatus("InternalFailure", err.Error(), 500)
+}
+
+// ErrorWithStatus generates an error response with HTTP status other than 400, e.g. 403 for authorization errors.
+func (resp Response) ErrorWithStatus(ErrorCode string, ErrorMessage string, StatusCode int) (Body string, Code int) {
+	if resp.Req.Protocol == JSONProtocol {
+		resp.setContentType(JSONContentType)
+		if resp.Header != nil {
+			resp.Header.Set("x-amzn-query-error", ErrorCode+";"+errorType(StatusCode))
+		}
+
+		var ErrorType, ok = jsonErrorTypes[ErrorCode]
+		if !ok {
+			ErrorType = strings.TrimPrefix(ErrorCode, "AWS.SimpleQueueService.")
+		}
+		var ErrorBody, _ = json.Marshal(map[string]string{
+			"__type":  "com.amazonaws.sqs#" + ErrorType,
+			"message": ErrorMessage,
+		})
+		return string(ErrorBody), StatusCode
+	}
+
+	resp.setContentType("text/xml")
+	return resp.xmlBody(xmlErrorResponse{
+		Error: xmlError{
+			Type:    errorType(StatusCode),
+			Code:    ErrorCode,
+			Message: ErrorMessage,
+		},
+		RequestID: resp.Req.ID,
+	}, StatusCode)
+}
+
+// xmlBody serializes a response of query protocol, failing with a plain text body if it could not be serialized.
+func (resp Response) xmlBody(Envelope interface{}, StatusCode int) (Body string, Code int) {
+	var ResponseBody bytes.Buffer
+	ResponseBody.WriteString(xml.Header)
+	if err := xml.NewEncoder(&ResponseBody).Encode(Envelope); err != nil {
+		resp.setContentType("text/plain")
+		return fmt.Sprintf("InternalFailure: %s", err), 500
+	}
+
+	return ResponseBody.String(), StatusCode
+}
+
+// errorType tells whether an error is caused by a client (Sender) or by the server (Receiver).
+func errorType(StatusCode int) string {
+	if StatusCode >= 500 {
+		return "Receiver"
+	}
+
+	return "Sender"
+}
+
+func (resp Response) setContentType(ContentType string) {
+	if resp.Header != nil {
+		resp.Header.Set("Content-Type", ContentType)
+	}
 }
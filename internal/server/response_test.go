@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/xml"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// xmlText is a string of characters XML 1.0 can represent, including astral plane ones.
+type xmlText string
+
+// xmlRuneRanges are ranges of the Char production of XML 1.0, markup characters are
+// in the ASCII range and picked as often as other ranges.
+var xmlRuneRanges = [][2]rune{
+	{'\t', '\n'},
+	{'\r', '\r'},
+	{0x20, 0x7F},
+	{0x80, 0xD7FF},
+	{0xE000, 0xFFFD},
+	{0x10000, 0x10FFFF},
+}
+
+func (xmlText) Generate(Rand *rand.Rand, Size int) reflect.Value {
+	var Runes = make([]rune, Rand.Intn(Size+1))
+	for i := range Runes {
+		var Range = xmlRuneRanges[Rand.Intn(len(xmlRuneRanges))]
+		Runes[i] = Range[0] + Rand.Int31n(Range[1]-Range[0]+1)
+	}
+
+	return reflect.ValueOf(xmlText(Runes))
+}
+
+func TestSuccessXMLRoundTrip(t *testing.T) {
+	var RoundTrip = func(Text xmlText) bool {
+		var Body = string(Text)
+		var resp = Response{Req: Request{ID: "request-id", Protocol: QueryProtocol}}
+		var ResponseBody, Code = resp.Success("ReceiveMessage", ReceiveMessageResult{
+			Messages: []MessageResult{{
+				MessageID: "message-id",
+				Body:      Body,
+				MessageAttributes: MessageAttributeMap{
+					"text": {DataType: "String", StringValue: Body},
+				},
+			}},
+		})
+		if Code != 200 {
+			t.Logf("expected status 200, got %d: %s", Code, ResponseBody)
+			return false
+		}
+
+		var Response struct {
+			XMLName xml.Name `xml:"http://queue.amazonaws.com/doc/2012-11-05/ ReceiveMessageResponse"`
+			Result  struct {
+				Message []struct {
+					MessageId        string
+					Body             string
+					MessageAttribute []struct {
+						Name  string
+						Value MessageAttributeValueResult
+					}
+				}
+			} `xml:"ReceiveMessageResult"`
+			ResponseMetadata struct {
+				RequestId string
+			}
+		}
+		if err := xml.Unmarshal([]byte(ResponseBody), &Response); err != nil {
+			t.Logf("could not parse response: %s", err)
+			return false
+		}
+		if Response.ResponseMetadata.RequestId != "request-id" || len(Response.Result.Message) != 1 {
+			t.Logf("unexpected response: %s", ResponseBody)
+			return false
+		}
+
+		var Message = Response.Result.Message[0]
+		if Message.MessageId != "message-id" || Message.Body != Body {
+			t.Logf("expected body %q, got %q", Body, Message.Body)
+			return false
+		}
+		if len(Message.MessageAttribute) != 1 || Message.MessageAttribute[0].Name != "text" ||
+			Message.MessageAttribute[0].Value.StringValue != Body || Message.MessageAttribute[0].Value.DataType != "String" {
+			t.Logf("expected attribute value %q, got %+v", Body, Message.MessageAttribute)
+			return false
+		}
+
+		return true
+	}
+
+	if err := quick.Check(RoundTrip, &quick.Config{MaxCount: 1000}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+// Results of actions are serialized both to XML for the query protocol and to JSON for the JSON protocol.
+// Field names differ between the protocols mostly for lists, which are flattened in XML
+// and named in plural in JSON.
+
+// AttributeMap represents attributes which are serialized as a JSON object
+// and as a sequence of Name/Value elements in XML.
+type AttributeMap map[string]string
+
+// MarshalXML implements xml.Marshaler.
+func (Attributes AttributeMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	for _, Name := range sortedKeys(Attributes) {
+		var Attribute = struct {
+			Name  string
+			Value string
+		}{Name, Attributes[Name]}
+		if err := e.EncodeElement(Attribute, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TagMap represents queue tags which are serialized as a JSON object
+// and as a sequence of Key/Value elements in XML.
+type TagMap map[string]string
+
+// MarshalXML implements xml.Marshaler.
+func (Tags TagMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	for _, Key := range sortedKeys(Tags) {
+		var Tag = struct {
+			Key   string
+			Value string
+		}{Key, Tags[Key]}
+		if err := e.EncodeElement(Tag, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MessageAttributeValueResult represents a value of a message attribute.
+// BinaryValue is base64-encoded.
+type MessageAttributeValueResult struct {
+	StringValue string `xml:",omitempty" json:",omitempty"`
+	BinaryValue string `xml:",omitempty" json:",omitempty"`
+	DataType    string
+}
+
+// MessageAttributeMap represents message attributes which are serialized as a JSON object
+// and as a sequence of Name/Value elements in XML.
+type MessageAttributeMap map[string]MessageAttributeValueResult
+
+// MarshalXML implements xml.Marshaler.
+func (Attributes MessageAttributeMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var Names = make([]string, 0, len(Attributes))
+	for Name := range Attributes {
+		Names = append(Names, Name)
+	}
+	sort.Strings(Names)
+
+	for _, Name := range Names {
+		var Attribute = struct {
+			Name  string
+			Value MessageAttributeValueResult
+		}{Name, Attributes[Name]}
+		if err := e.EncodeElement(Attribute, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(Map map[string]string) []string {
+	var Keys = make([]string, 0, len(Map))
+	for Key := range Map {
+		Keys = append(Keys, Key)
+	}
+	sort.Strings(Keys)
+
+	return Keys
+}
+
+// BatchResultErrorEntry represents a failed entry of a batch action.
+type BatchResultErrorEntry struct {
+	ID          string `xml:"Id" json:"Id"`
+	Code        string
+	Message     string
+	SenderFault bool
+}
+
+// ChangeMessageVisibilityBatchResultEntry represents a successful entry of ChangeMessageVisibilityBatch.
+type ChangeMessageVisibilityBatchResultEntry struct {
+	ID string `xml:"Id" json:"Id"`
+}
+
+// ChangeMessageVisibilityBatchResult represents a result of ChangeMessageVisibilityBatch.
+type ChangeMessageVisibilityBatchResult struct {
+	Successful []ChangeMessageVisibilityBatchResultEntry `xml:"ChangeMessageVisibilityBatchResultEntry"`
+	Failed     []BatchResultErrorEntry                   `xml:"BatchResultErrorEntry"`
+}
+
+// CreateQueueResult represents a result of CreateQueue.
+type CreateQueueResult struct {
+	QueueURL string `xml:"QueueUrl" json:"QueueUrl"`
+}
+
+// DeleteMessageBatchResultEntry represents a successful entry of DeleteMessageBatch.
+type DeleteMessageBatchResultEntry struct {
+	ID string `xml:"Id" json:"Id"`
+}
+
+// DeleteMessageBatchResult represents a result of DeleteMessageBatch.
+type DeleteMessageBatchResult struct {
+	Successful []DeleteMessageBatchResultEntry `xml:"DeleteMessageBatchResultEntry"`
+	Failed     []BatchResultErrorEntry         `xml:"BatchResultErrorEntry"`
+}
+
+// GetQueueAttributesResult represents a result of GetQueueAttributes.
+type GetQueueAttributesResult struct {
+	Attributes AttributeMap `xml:"Attribute" json:",omitempty"`
+}
+
+// GetQueueURLResult represents a result of GetQueueUrl.
+type GetQueueURLResult struct {
+	QueueURL string `xml:"QueueUrl" json:"QueueUrl"`
+}
+
+// ListDeadLetterSourceQueuesResult represents a result of ListDeadLetterSourceQueues.
+type ListDeadLetterSourceQueuesResult struct {
+	QueueURLs []string `xml:"QueueUrl" json:"queueUrls"`
+}
+
+// ListQueueTagsResult represents a result of ListQueueTags.
+type ListQueueTagsResult struct {
+	Tags TagMap `xml:"Tag" json:",omitempty"`
+}
+
+// ListQueuesResult represents a result of ListQueues.
+type ListQueuesResult struct {
+	QueueURLs []string `xml:"QueueUrl" json:"QueueUrls,omitempty"`
+	NextToken string   `xml:"NextToken,omitempty" json:"NextToken,omitempty"`
+}
+
+// MessageResult represents a message returned by ReceiveMessage.
+type MessageResult struct {
+	MessageID              string `xml:"MessageId" json:"MessageId"`
+	ReceiptHandle          string
+	MD5OfBody              string
+	Body                   string
+	Attributes             AttributeMap        `xml:"Attribute" json:",omitempty"`
+	MD5OfMessageAttributes string              `xml:",omitempty" json:",omitempty"`
+	MessageAttributes      MessageAttributeMap `xml:"MessageAttribute" json:",omitempty"`
+}
+
+// ReceiveMessageResult represents a result of ReceiveMessage.
+type ReceiveMessageResult struct {
+	Messages []MessageResult `xml:"Message" json:",omitempty"`
+}
+
+// SendMessageResult represents a result of SendMessage.
+type SendMessageResult struct {
+	MD5OfMessageBody             string
+	MD5OfMessageAttributes       string `xml:",omitempty" json:",omitempty"`
+	MD5OfMessageSystemAttributes string `xml:",omitempty" json:",omitempty"`
+	MessageID                    string `xml:"MessageId" json:"MessageId"`
+	SequenceNumber               string `xml:",omitempty" json:",omitempty"`
+}
+
+// SendMessageBatchResultEntry represents a successful entry of SendMessageBatch.
+type SendMessageBatchResultEntry struct {
+	ID                           string `xml:"Id" json:"Id"`
+	MD5OfMessageBody             string
+	MD5OfMessageAttributes       string `xml:",omitempty" json:",omitempty"`
+	MD5OfMessageSystemAttributes string `xml:",omitempty" json:",omitempty"`
+	MessageID                    string `xml:"MessageId" json:"MessageId"`
+	SequenceNumber               string `xml:",omitempty" json:",omitempty"`
+}
+
+// SendMessageBatchResult represents a result of SendMessageBatch.
+type SendMessageBatchResult struct {
+	Successful []SendMessageBatchResultEntry `xml:"SendMessageBatchResultEntry"`
+	Failed     []BatchResultErrorEntry       `xml:"BatchResultErrorEntry"`
+}
@@ -0,0 +1,131 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/events"
+	"github.com/andreyst/go-sqs/internal/limits"
+	"github.com/andreyst/go-sqs/internal/queue"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
+)
+
+var fifoIDPattern = regexp.MustCompile("^[[:alnum:][:punct:]]{1,128}$")
+
+// validateFifoParameters checks that a message group ID is given only for a FIFO queue and is required by it,
+// while per-message delay is not allowed for a FIFO queue.
+func validateFifoParameters(Queue *queue.Queue, Message *queue.Message, DelaySeconds int) (events.SendResponseEvent, bool) {
+	var Error = func(ErrorCode string, ErrorMessage string) (events.SendResponseEvent, bool) {
+		return events.SendResponseEvent{
+			Ok:           false,
+			ErrorCode:    ErrorCode,
+			ErrorMessage: ErrorMessage,
+		}, false
+	}
+
+	if !Queue.FifoQueue {
+		if Message.MessageGroupID != "" || Message.MessageDeduplicationID != "" {
+			return Error("InvalidParameterValue", "The request include parameter that is not valid for this queue type.")
+		}
+		return events.SendResponseEvent{}, true
+	}
+
+	if DelaySeconds != events.QueueDelaySeconds {
+		return Error("InvalidParameterValue", fmt.Sprintf("Value %d for parameter DelaySeconds is invalid. Reason: The request include parameter that is not valid for this queue type.", DelaySeconds))
+	}
+	if Message.MessageGroupID == "" {
+		return Error("MissingParameter", "The request must contain the parameter MessageGroupId.")
+	}
+	if !fifoIDPattern.MatchString(Message.MessageGroupID) {
+		return Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter MessageGroupId is invalid. Reason: MessageGroupId can only include alphanumeric and punctuation characters. 1 to 128 in length.", Message.MessageGroupID))
+	}
+	if Message.MessageDeduplicationID != "" && !fifoIDPattern.MatchString(Message.MessageDeduplicationID) {
+		return Error("InvalidParameterValue", fmt.Sprintf("Value %s for parameter MessageDeduplicationId is invalid. Reason: MessageDeduplicationId can only include alphanumeric and punctuation characters. 1 to 128 in length.", Message.MessageDeduplicationID))
+	}
+
+	return events.SendResponseEvent{}, true
+}
+
+// deduplicateFifoMessage assigns deduplication ID and sequence number to a message sent to a FIFO queue.
+// It returns false if the message should not be stored, either because of an error or because
+// a message with the same deduplication ID was already sent within the deduplication interval.
+func deduplicateFifoMessage(Queue *queue.Queue, Message *queue.Message) (events.SendResponseEvent, bool) {
+	if Message.MessageDeduplicationID == "" {
+		if !Queue.ContentBasedDeduplication {
+			return events.SendResponseEvent{
+				Ok:           false,
+				ErrorCode:    "InvalidParameterValue",
+				ErrorMessage: "The queue should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly",
+			}, false
+		}
+		var Hash = sha256.Sum256([]byte(Message.Body))
+		Message.MessageDeduplicationID = hex.EncodeToString(Hash[:])
+	}
+
+	// Deduplication IDs of different message groups do not clash with messageGroup deduplication scope
+	var DeduplicationKey = Message.MessageDeduplicationID
+	if Queue.DeduplicationScope == "messageGroup" {
+		DeduplicationKey = Message.MessageGroupID + "/" + DeduplicationKey
+	}
+
+	var Now = time.Now().Unix()
+	var Entry, ok = Queue.DeduplicationEntries[DeduplicationKey]
+	if ok && Entry.ExpiresAt > Now {
+		return events.SendResponseEvent{
+			Ok:             true,
+			MessageID:      Entry.MessageID,
+			SequenceNumber: Entry.SequenceNumber,
+		}, false
+	}
+
+	Queue.LastSequenceNumber++
+	// Fixed width keeps lexicographical order of sequence numbers the same as numerical
+	Message.SequenceNumber = fmt.Sprintf("%020d", Queue.LastSequenceNumber)
+	Queue.DeduplicationEntries[DeduplicationKey] = queue.DeduplicationEntry{
+		MessageID:      Message.MessageID,
+		SequenceNumber: Message.SequenceNumber,
+		ExpiresAt:      Now + limits.DeduplicationInterval,
+	}
+
+	return events.SendResponseEvent{}, true
+}
+
+func expireDeduplicationEntries(Queue *queue.Queue) {
+	var Now = time.Now().Unix()
+	for MessageDeduplicationID, Entry := range Queue.DeduplicationEntries {
+		if Entry.ExpiresAt <= Now {
+			delete(Queue.DeduplicationEntries, MessageDeduplicationID)
+		}
+	}
+}
+
+// collectFifoMessages returns messages of ready message groups, taking groups in order of sequence numbers
+// of their first messages and messages of a group in order until one that is not visible. So messages of
+// a group are never received out of order or while an earlier message of the same group is in flight,
+// and groups which are not ready are not looked at.
+func collectFifoMessages(Queues *queuemgr.Manager, Queue *queue.Queue, MaxNumberOfMessages int, VisibilityTimeout int, Now int64) []interface{} {
+	var FoundMessages = make([]interface{}, 0, MaxNumberOfMessages)
+	for len(FoundMessages) < MaxNumberOfMessages {
+		var Message = Queue.MessageGroups.PeekReady()
+		if Message == nil {
+			break
+		}
+
+		// Receiving or moving the first message of a group makes it not ready unless it is taken further
+		for Message != nil && len(FoundMessages) < MaxNumberOfMessages && Queue.VisibleMessages.Contains(Message) {
+			var Next = Queue.MessageGroups.Next(Message)
+			if Queue.RedrivePolicy != nil && Message.ApproximateReceiveCount >= Queue.RedrivePolicy.MaxReceiveCount {
+				moveToDeadLetterQueue(Queues, Queue, Message)
+			} else {
+				markMessageReceived(Queue, Message, Now, VisibilityTimeout)
+				FoundMessages = append(FoundMessages, Message)
+			}
+			Message = Next
+		}
+	}
+
+	return FoundMessages
+}
@@ -0,0 +1,159 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/events"
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+func newTestFifoQueue() *queue.Queue {
+	return newQueue("000000000000", "us-east-1", "test.fifo", map[string]string{
+		"FifoQueue":                 "true",
+		"ContentBasedDeduplication": "true",
+	}, nil)
+}
+
+func sendTestMessage(t testing.TB, Queue *queue.Queue, Message *queue.Message) {
+	t.Helper()
+	var ReturnChan = make(chan events.SendResponseEvent, 1)
+	sendMessage(Queue, events.SendRequestEvent{
+		Message:      Message,
+		DelaySeconds: events.QueueDelaySeconds,
+		ReturnChan:   ReturnChan,
+	})
+	if SendResponseEvent := <-ReturnChan; !SendResponseEvent.Ok {
+		t.Fatalf("could not send a message: %s", SendResponseEvent.ErrorMessage)
+	}
+}
+
+func sendTestFifoMessage(t testing.TB, Queue *queue.Queue, MessageGroupID string, Body string) *queue.Message {
+	var Message = newTestMessage(time.Now())
+	Message.Body = Body
+	Message.MessageGroupID = MessageGroupID
+	sendTestMessage(t, Queue, Message)
+	return Message
+}
+
+func assertBodies(t *testing.T, FoundMessages []interface{}, Bodies ...string) {
+	t.Helper()
+	var FoundBodies = make([]string, len(FoundMessages))
+	for i, Message := range FoundMessages {
+		FoundBodies[i] = Message.(*queue.Message).Body
+	}
+	if fmt.Sprint(FoundBodies) != fmt.Sprint(Bodies) {
+		t.Fatalf("expected messages %v, got %v", Bodies, FoundBodies)
+	}
+}
+
+func TestCollectFifoMessages(t *testing.T) {
+	var Queue = newTestFifoQueue()
+	var First = sendTestFifoMessage(t, Queue, "a", "a1")
+	sendTestFifoMessage(t, Queue, "b", "b1")
+	sendTestFifoMessage(t, Queue, "a", "a2")
+	sendTestFifoMessage(t, Queue, "c", "c1")
+	sendTestFifoMessage(t, Queue, "c", "c2")
+
+	assertBodies(t, collectMessages(nil, Queue, 1, 30, time.Now().Unix()), "a1")
+	// Group a is blocked while its first message is in flight
+	assertBodies(t, collectMessages(nil, Queue, 10, 30, time.Now().Unix()), "b1", "c1", "c2")
+	assertBodies(t, collectMessages(nil, Queue, 10, 30, time.Now().Unix()))
+	assertCounters(t, Queue, 1, 4, 0)
+
+	removeMessage(Queue, First)
+	assertBodies(t, collectMessages(nil, Queue, 10, 30, time.Now().Unix()), "a2")
+	assertCounters(t, Queue, 0, 4, 0)
+}
+
+func TestCollectFifoMessagesAfterVisibilityTimeout(t *testing.T) {
+	var Queue = newTestFifoQueue()
+	var First = sendTestFifoMessage(t, Queue, "a", "a1")
+	sendTestFifoMessage(t, Queue, "a", "a2")
+	assertBodies(t, collectMessages(nil, Queue, 1, 30, time.Now().Unix()), "a1")
+
+	// In flight message is returned again before the following one once it becomes visible
+	detachMessage(Queue, First)
+	First.VisibilityDeadline = time.Now().Unix() - 1
+	attachMessage(Queue, First, time.Now().Unix())
+	assertBodies(t, collectMessages(nil, Queue, 10, 30, time.Now().Unix()), "a1", "a2")
+
+	expireMessages(Queue, time.Now().Add(time.Duration(Queue.MessageRetentionPeriod+1)*time.Second))
+	assertCounters(t, Queue, 0, 0, 0)
+	if Queue.MessageGroups.PeekReady() != nil {
+		t.Fatal("expected no ready message groups after all messages expired")
+	}
+}
+
+func TestPromoteFifoMessagesAtVisibilityDeadline(t *testing.T) {
+	var Queue = newTestFifoQueue()
+	var Now = time.Now().Unix()
+	sendTestFifoMessage(t, Queue, "a", "a1")
+	assertBodies(t, collectMessages(nil, Queue, 1, 30, Now), "a1")
+
+	// Message becomes visible in the second of its deadline, the same as it is attached by attachMessage
+	promoteMessages(Queue, Now+29)
+	assertCounters(t, Queue, 0, 1, 0)
+	if Queue.MessageGroups.PeekReady() != nil {
+		t.Fatal("expected message group not to be ready before visibility deadline")
+	}
+	promoteMessages(Queue, Now+30)
+	assertCounters(t, Queue, 1, 0, 0)
+	if Message := Queue.MessageGroups.PeekReady(); Message == nil || Message.Body != "a1" {
+		t.Fatal("expected message group to be ready at visibility deadline")
+	}
+}
+
+// newBacklogFifoQueue creates a FIFO queue with NumMessages spread evenly across NumGroups message groups.
+func newBacklogFifoQueue(b *testing.B, NumMessages int, NumGroups int) *queue.Queue {
+	var Queue = newTestFifoQueue()
+	for i := 0; i < NumMessages; i++ {
+		sendTestFifoMessage(b, Queue, fmt.Sprint(i%NumGroups), fmt.Sprint(i))
+	}
+	return Queue
+}
+
+func BenchmarkReceiveFifoMessageBacklog(b *testing.B) {
+	var Queue = newBacklogFifoQueue(b, benchmarkBacklog, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var FoundMessages = collectMessages(nil, Queue, 10, 30, time.Now().Unix())
+		if len(FoundMessages) == 0 {
+			b.StopTimer()
+			for j := 0; j < benchmarkBacklog; j++ {
+				sendTestFifoMessage(b, Queue, fmt.Sprint(j%1000), fmt.Sprint(i, j))
+			}
+			b.StartTimer()
+		}
+		for _, Message := range FoundMessages {
+			removeMessage(Queue, Message.(*queue.Message))
+		}
+	}
+}
+
+// BenchmarkServeFifoReceiveWaiters measures a tick of parked receive requests when
+// the first message of every group of a deep backlog is in flight.
+func BenchmarkServeFifoReceiveWaiters(b *testing.B) {
+	var Queue = newBacklogFifoQueue(b, benchmarkBacklog, 1000)
+	for len(collectMessages(nil, Queue, 10, 3600, time.Now().Unix())) > 0 {
+	}
+	var Done = make(chan struct{})
+	for i := 0; i < 100; i++ {
+		Queue.ReceiveWaiters = append(Queue.ReceiveWaiters, queue.ReceiveWaiter{
+			Event: events.ReceiveRequestEvent{
+				MaxNumberOfMessages: 10,
+				VisibilityTimeout:   30,
+				Done:                Done,
+				ReturnChan:          make(chan events.ReceiveResponseEvent, 1),
+			},
+			WaitDeadline: time.Now().Add(time.Hour),
+		})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		serveReceiveWaiters(nil, Queue)
+	}
+}
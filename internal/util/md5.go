@@ -0,0 +1,57 @@
+package util
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+// MD5OfMessageBody calculates MD5 digest of a message body as returned by SQS.
+func MD5OfMessageBody(Body string) string {
+	var Hash = md5.Sum([]byte(Body))
+	return hex.EncodeToString(Hash[:])
+}
+
+// MD5OfMessageAttributes calculates MD5 digest of message attributes using the algorithm specified by AWS:
+// attributes are sorted by name, then for each of them its name, data type and value are encoded
+// with 4-byte big-endian length prefixes, with a transport type byte before the value (1 for
+// String and Number, 2 for Binary). Empty string is returned if there are no attributes.
+func MD5OfMessageAttributes(Attributes map[string]queue.MessageAttributeValue) string {
+	if len(Attributes) == 0 {
+		return ""
+	}
+
+	var Names = make([]string, 0, len(Attributes))
+	for Name := range Attributes {
+		Names = append(Names, Name)
+	}
+	sort.Strings(Names)
+
+	var Hash = md5.New()
+	for _, Name := range Names {
+		var Value = Attributes[Name]
+		writeLengthPrefixed(Hash, []byte(Name))
+		writeLengthPrefixed(Hash, []byte(Value.DataType))
+		if strings.HasPrefix(Value.DataType, "Binary") {
+			Hash.Write([]byte{2})
+			writeLengthPrefixed(Hash, Value.BinaryValue)
+		} else {
+			Hash.Write([]byte{1})
+			writeLengthPrefixed(Hash, []byte(Value.StringValue))
+		}
+	}
+
+	return hex.EncodeToString(Hash.Sum(nil))
+}
+
+func writeLengthPrefixed(Writer io.Writer, Data []byte) {
+	var Length = make([]byte, 4)
+	binary.BigEndian.PutUint32(Length, uint32(len(Data)))
+	Writer.Write(Length)
+	Writer.Write(Data)
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/andreyst/go-sqs/internal/events"
+	"github.com/andreyst/go-sqs/internal/queue"
+)
+
+// PolicyVersion is a version of the policy language used in policies created by AddPermission.
+const PolicyVersion = "2012-10-17"
+
+// ParsePolicy parses a Policy attribute value.
+func ParsePolicy(Value string) (*queue.Policy, bool) {
+	var Policy queue.Policy
+	if err := json.Unmarshal([]byte(Value), &Policy); err != nil {
+		return nil, false
+	}
+
+	if len(Policy.Statement) == 0 {
+		return nil, false
+	}
+	for _, Statement := range Policy.Statement {
+		if Statement.Effect != "Allow" && Statement.Effect != "Deny" {
+			return nil, false
+		}
+		if len(Statement.Action) == 0 {
+			return nil, false
+		}
+	}
+
+	return &Policy, true
+}
+
+// changePermission adds a statement labeled with Event.Label to the queue policy,
+// or removes it if Event.Statement is nil. Policy is replaced rather than modified in place.
+func changePermission(Queue *queue.Queue, Event events.PermissionRequestEvent) {
+	var Statements []queue.PolicyStatement
+	var LabelIndex = -1
+	if Queue.Policy != nil {
+		for i, Statement := range Queue.Policy.Statement {
+			if Statement.Sid == Event.Label {
+				LabelIndex = i
+			}
+			Statements = append(Statements, Statement)
+		}
+	}
+
+	if Event.Statement == nil {
+		if LabelIndex == -1 {
+			Event.ReturnChan <- events.PermissionResponseEvent{
+				Ok:           false,
+				ErrorCode:    "InvalidParameterValue",
+				ErrorMessage: fmt.Sprintf("Value %s for parameter Label is invalid. Reason: can't find label on existing policy.", Event.Label),
+			}
+			return
+		}
+		Statements = append(Statements[:LabelIndex], Statements[LabelIndex+1:]...)
+	} else {
+		if LabelIndex != -1 {
+			Event.ReturnChan <- events.PermissionResponseEvent{
+				Ok:           false,
+				ErrorCode:    "InvalidParameterValue",
+				ErrorMessage: fmt.Sprintf("Value %s for parameter Label is invalid. Reason: Already exists.", Event.Label),
+			}
+			return
+		}
+		Statements = append(Statements, *Event.Statement.(*queue.PolicyStatement))
+	}
+
+	if len(Statements) == 0 {
+		Queue.Policy = nil
+	} else {
+		var Policy = queue.Policy{
+			Version:   PolicyVersion,
+			ID:        fmt.Sprintf("%s/SQSDefaultPolicy", Queue.QueueArn),
+			Statement: Statements,
+		}
+		if Queue.Policy != nil {
+			Policy.Version = Queue.Policy.Version
+			Policy.ID = Queue.Policy.ID
+		}
+		Queue.Policy = &Policy
+	}
+
+	Event.ReturnChan <- events.PermissionResponseEvent{
+		Ok: true,
+	}
+}
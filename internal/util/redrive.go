@@ -0,0 +1,136 @@
+package util
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/andreyst/go-sqs/internal/events"
+	"github.com/andreyst/go-sqs/internal/limits"
+	"github.com/andreyst/go-sqs/internal/queue"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
+)
+
+// ParseRedrivePolicy parses a RedrivePolicy attribute value.
+// AWS accepts maxReceiveCount both as a number and as a string.
+func ParseRedrivePolicy(Value string) (*queue.RedrivePolicy, bool) {
+	var RawRedrivePolicy struct {
+		DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+		MaxReceiveCount     interface{} `json:"maxReceiveCount"`
+	}
+	if err := json.Unmarshal([]byte(Value), &RawRedrivePolicy); err != nil {
+		return nil, false
+	}
+
+	var MaxReceiveCount int
+	switch RawMaxReceiveCount := RawRedrivePolicy.MaxReceiveCount.(type) {
+	case float64:
+		MaxReceiveCount = int(RawMaxReceiveCount)
+		if float64(MaxReceiveCount) != RawMaxReceiveCount {
+			return nil, false
+		}
+	case string:
+		var err error
+		if MaxReceiveCount, err = strconv.Atoi(RawMaxReceiveCount); err != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	if RawRedrivePolicy.DeadLetterTargetArn == "" || MaxReceiveCount < 1 || MaxReceiveCount > limits.MaxMaxReceiveCount {
+		return nil, false
+	}
+
+	return &queue.RedrivePolicy{
+		DeadLetterTargetArn: RawRedrivePolicy.DeadLetterTargetArn,
+		MaxReceiveCount:     MaxReceiveCount,
+	}, true
+}
+
+// ParseRedriveAllowPolicy parses a RedriveAllowPolicy attribute value.
+func ParseRedriveAllowPolicy(Value string) (*queue.RedriveAllowPolicy, bool) {
+	var RedriveAllowPolicy queue.RedriveAllowPolicy
+	if err := json.Unmarshal([]byte(Value), &RedriveAllowPolicy); err != nil {
+		return nil, false
+	}
+
+	switch RedriveAllowPolicy.RedrivePermission {
+	case "allowAll", "denyAll":
+		if len(RedriveAllowPolicy.SourceQueueArns) > 0 {
+			return nil, false
+		}
+	case "byQueue":
+		if len(RedriveAllowPolicy.SourceQueueArns) == 0 || len(RedriveAllowPolicy.SourceQueueArns) > limits.MaxRedriveSourceQueueArns {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	return &RedriveAllowPolicy, true
+}
+
+// IsRedriveAllowed checks if RedriveAllowPolicy of a dead-letter queue permits
+// a source queue to move messages into it. Nil policy allows all source queues.
+func IsRedriveAllowed(RedriveAllowPolicy *queue.RedriveAllowPolicy, SourceQueueArn string) bool {
+	if RedriveAllowPolicy == nil {
+		return true
+	}
+
+	switch RedriveAllowPolicy.RedrivePermission {
+	case "denyAll":
+		return false
+	case "byQueue":
+		for _, Arn := range RedriveAllowPolicy.SourceQueueArns {
+			if Arn == SourceQueueArn {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// moveToDeadLetterQueue hands a message over to the dead-letter queue, whose actor
+// decides whether to accept it, since the state of that queue is not owned by this one.
+func moveToDeadLetterQueue(Queues *queuemgr.Manager, Queue *queue.Queue, Message *queue.Message) {
+	removeMessage(Queue, Message)
+
+	// Message is dropped if dead-letter queue is gone, same as in AWS
+	var DeadLetterQueue = Queues.GetByArn(Queue.RedrivePolicy.DeadLetterTargetArn)
+	if DeadLetterQueue == nil {
+		return
+	}
+
+	Message.ReceiptHandle = ""
+	Message.VisibilityDeadline = 0
+	Message.ApproximateReceiveCount = 0
+	Message.ApproximateFirstReceiveTimestamp = 0
+	Message.DeadLetterQueueSourceArn = Queue.QueueArn
+	// Send asynchronously, since actor of the dead-letter queue could be sending to this queue at the same time
+	go func() {
+		select {
+		case DeadLetterQueue.RedriveChannel <- events.RedriveRequestEvent{
+			Message: Message,
+		}:
+		case <-DeadLetterQueue.Deleted:
+		}
+	}()
+}
+
+// acceptDeadLetterMessage stores a message moved from a source queue unless RedriveAllowPolicy
+// was changed to deny the source queue after its RedrivePolicy was set.
+func acceptDeadLetterMessage(Queue *queue.Queue, Event events.RedriveRequestEvent) {
+	var Message = Event.Message.(*queue.Message)
+	if !IsRedriveAllowed(Queue.RedriveAllowPolicy, Message.DeadLetterQueueSourceArn) {
+		return
+	}
+	if Queue.FifoQueue {
+		if _, ok := deduplicateFifoMessage(Queue, Message); !ok {
+			return
+		}
+	}
+
+	storeMessage(Queue, Message)
+}
@@ -1,118 +1,451 @@
 package util
 
 import (
+	"encoding/json"
 	"fmt"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/andreyst/go-sqs/internal/events"
 	"github.com/andreyst/go-sqs/internal/limits"
 	"github.com/andreyst/go-sqs/internal/queue"
+	"github.com/andreyst/go-sqs/internal/queuemgr"
 	uuid "github.com/satori/go.uuid"
 )
 
-// GetQueueByName TODO: Add comment
-func GetQueueByName(Queues *sync.Map, QueueName string) (*queue.Queue, string) {
-	// TODO: Refactor to use map instead of full scan
-	var FoundQueue *queue.Queue
-	var FoundQueueURL string
-	Queues.Range(func(QueueURL, v interface{}) bool {
-		var Queue = v.(*queue.Queue)
-		if Queue.QueueName == QueueName {
-			FoundQueue = Queue
-			FoundQueueURL = QueueURL.(string)
-			return false
-		}
-		return true
-	})
-
-	return FoundQueue, FoundQueueURL
-}
-
-// CreateQueue TODO: Add comment
-func CreateQueue(Queues *sync.Map, QueueName string) (*queue.Queue, string) {
-	var ExistingQueue, ExistingQueueURL = GetQueueByName(Queues, QueueName)
+// CreateQueue creates a queue and starts its actor. An existing queue with the same name is returned as is,
+// the second result tells whether the queue was created. Nil queue is returned if the name could not
+// be reused yet after deletion of a queue.
+func CreateQueue(Queues *queuemgr.Manager, AccountID string, Region string, QueueName string, Attributes map[string]string, Tags map[string]string) (*queue.Queue, bool) {
+	var ExistingQueue = Queues.GetByName(AccountID, Region, QueueName)
 	if ExistingQueue != nil {
-		return ExistingQueue, ExistingQueueURL
+		return ExistingQueue, false
+	}
+
+	var Queue = newQueue(AccountID, Region, QueueName, Attributes, Tags)
+	if ExistingQueue, Created := Queues.Create(Queue); !Created {
+		return ExistingQueue, false
 	}
 
-	// TODO: Move protocol/hostname prefix to app config
-	var QueueURL = fmt.Sprintf("http://localhost/%s", QueueName)
+	go queueActor(Queues, Queue)
+	return Queue, true
+}
+
+// newQueue builds a queue with default attributes overridden by validated Attributes, without starting its actor.
+func newQueue(AccountID string, Region string, QueueName string, Attributes map[string]string, Tags map[string]string) *queue.Queue {
 	var Queue = queue.Queue{
-		QueueName: QueueName,
-		// TODO: Create good ARN
-		QueueArn:                              QueueName,
+		QueueName:                             QueueName,
+		QueueArn:                              queuemgr.QueueArn(Region, AccountID, QueueName),
+		AccountID:                             AccountID,
+		Region:                                Region,
 		ApproximateNumberOfMessages:           0,
 		ApproximateNumberOfMessagesNotVisible: 0,
 		ApproximateNumberOfMessagesDelayed:    0,
 		CreatedTimestamp:                      time.Now().Unix(),
 		LastModifiedTimestamp:                 time.Now().Unix(),
-		VisibilityTimeout:                     0,
-		MaximumMessageSize:                    262144,
-		MessageRetentionPeriod:                346500,
+		VisibilityTimeout:                     30,
+		MaximumMessageSize:                    limits.MaxMaximumMessageSize,
+		MessageRetentionPeriod:                345600,
 		DelaySeconds:                          0,
-		ReceiveMessageWaitTimeSeconds:         30,
+		ReceiveMessageWaitTimeSeconds:         0,
+		DeduplicationScope:                    "queue",
+		FifoThroughputLimit:                   "perQueue",
+		KmsDataKeyReusePeriodSeconds:          300,
+		SqsManagedSseEnabled:                  true,
+		DeduplicationEntries:                  make(map[string]queue.DeduplicationEntry),
+		Tags:                                  Tags,
 		Messages2:                             make(map[string]*queue.Message),
-		SendChannel:                           make(chan *queue.Message),
+		VisibleMessages:                       queue.NewVisibleHeap(),
+		InvisibleMessages:                     queue.NewInvisibleHeap(),
+		MessagesBySentTimestamp:               queue.NewRetentionHeap(),
+		SendChannel:                           make(chan events.SendRequestEvent),
+		RedriveChannel:                        make(chan events.RedriveRequestEvent),
 		ReceiveChannel:                        make(chan events.ReceiveRequestEvent),
 		DeleteChannel:                         make(chan events.DeleteRequestEvent),
+		ChangeVisibilityChannel:               make(chan events.ChangeVisibilityRequestEvent),
+		PurgeChannel:                          make(chan events.PurgeRequestEvent),
+		GetAttributesChannel:                  make(chan events.GetAttributesRequestEvent),
+		SetAttributesChannel:                  make(chan events.SetAttributesRequestEvent),
+		TagChannel:                            make(chan events.TagRequestEvent),
+		PermissionChannel:                     make(chan events.PermissionRequestEvent),
+		Deleted:                               make(chan struct{}),
 	}
-	Queues.Store(QueueURL, &Queue)
+	applyQueueAttributes(&Queue, Attributes)
+	if Queue.FifoQueue {
+		Queue.MessageGroups = queue.NewMessageGroups(Queue.VisibleMessages)
+	}
+
+	return &Queue
+}
+
+// GetQueueAttribute returns a value of a settable queue attribute formatted as in requests.
+func GetQueueAttribute(Queue *queue.Queue, Name string) string {
+	switch Name {
+	case "ContentBasedDeduplication":
+		return strconv.FormatBool(Queue.ContentBasedDeduplication)
+	case "DeduplicationScope":
+		return Queue.DeduplicationScope
+	case "DelaySeconds":
+		return strconv.Itoa(Queue.DelaySeconds)
+	case "FifoQueue":
+		return strconv.FormatBool(Queue.FifoQueue)
+	case "FifoThroughputLimit":
+		return Queue.FifoThroughputLimit
+	case "KmsDataKeyReusePeriodSeconds":
+		return strconv.Itoa(Queue.KmsDataKeyReusePeriodSeconds)
+	case "KmsMasterKeyId":
+		return Queue.KmsMasterKeyID
+	case "MaximumMessageSize":
+		return strconv.Itoa(Queue.MaximumMessageSize)
+	case "MessageRetentionPeriod":
+		return strconv.Itoa(Queue.MessageRetentionPeriod)
+	case "Policy":
+		if Queue.Policy != nil {
+			var Value, _ = json.Marshal(Queue.Policy)
+			return string(Value)
+		}
+	case "ReceiveMessageWaitTimeSeconds":
+		return strconv.Itoa(Queue.ReceiveMessageWaitTimeSeconds)
+	case "RedriveAllowPolicy":
+		if Queue.RedriveAllowPolicy != nil {
+			var Value, _ = json.Marshal(Queue.RedriveAllowPolicy)
+			return string(Value)
+		}
+	case "RedrivePolicy":
+		if Queue.RedrivePolicy != nil {
+			var Value, _ = json.Marshal(Queue.RedrivePolicy)
+			return string(Value)
+		}
+	case "SqsManagedSseEnabled":
+		return strconv.FormatBool(Queue.SqsManagedSseEnabled)
+	case "VisibilityTimeout":
+		return strconv.Itoa(Queue.VisibilityTimeout)
+	}
+
+	return ""
+}
 
-	go queueActor(&Queue)
-	return &Queue, QueueURL
+// applyQueueAttributes expects attributes to be validated beforehand. KMS and SQS managed encryption
+// replace each other, validation makes sure that only one of them is enabled by a request.
+func applyQueueAttributes(Queue *queue.Queue, Attributes map[string]string) {
+	for Name, Value := range Attributes {
+		var IntValue, _ = strconv.Atoi(Value)
+		switch Name {
+		case "ContentBasedDeduplication":
+			Queue.ContentBasedDeduplication = Value == "true"
+		case "DeduplicationScope":
+			Queue.DeduplicationScope = Value
+		case "DelaySeconds":
+			Queue.DelaySeconds = IntValue
+		case "FifoQueue":
+			Queue.FifoQueue = Value == "true"
+		case "FifoThroughputLimit":
+			Queue.FifoThroughputLimit = Value
+		case "KmsDataKeyReusePeriodSeconds":
+			Queue.KmsDataKeyReusePeriodSeconds = IntValue
+		case "KmsMasterKeyId":
+			Queue.KmsMasterKeyID = Value
+			if Value != "" {
+				Queue.SqsManagedSseEnabled = false
+			}
+		case "MaximumMessageSize":
+			Queue.MaximumMessageSize = IntValue
+		case "MessageRetentionPeriod":
+			Queue.MessageRetentionPeriod = IntValue
+		case "Policy":
+			Queue.Policy, _ = ParsePolicy(Value)
+		case "ReceiveMessageWaitTimeSeconds":
+			Queue.ReceiveMessageWaitTimeSeconds = IntValue
+		case "RedriveAllowPolicy":
+			Queue.RedriveAllowPolicy, _ = ParseRedriveAllowPolicy(Value)
+		case "RedrivePolicy":
+			Queue.RedrivePolicy, _ = ParseRedrivePolicy(Value)
+		case "SqsManagedSseEnabled":
+			Queue.SqsManagedSseEnabled = Value == "true"
+			if Queue.SqsManagedSseEnabled {
+				Queue.KmsMasterKeyID = ""
+			}
+		case "VisibilityTimeout":
+			Queue.VisibilityTimeout = IntValue
+		}
+	}
 }
 
-func queueActor(Queue *queue.Queue) {
+// queueActor owns state of a queue and serves requests to it until the queue is deleted.
+func queueActor(Queues *queuemgr.Manager, Queue *queue.Queue) {
+	var ReceiveWaitersTicker = time.NewTicker(limits.ReceiveWaitersCheckInterval)
+	defer ReceiveWaitersTicker.Stop()
+	var DeduplicationTicker = time.NewTicker(limits.DeduplicationCleanupInterval)
+	defer DeduplicationTicker.Stop()
+	var RetentionTicker = time.NewTicker(limits.RetentionCheckInterval)
+	defer RetentionTicker.Stop()
 	for {
 		select {
-		case Message := <-Queue.SendChannel:
-			sendMessage(Queue, Message)
+		case <-Queue.Deleted:
+			// Requests sent to the queue or parked by it are failed by their handlers
+			return
+		case <-ReceiveWaitersTicker.C:
+			serveReceiveWaiters(Queues, Queue)
+		case <-DeduplicationTicker.C:
+			expireDeduplicationEntries(Queue)
+		case <-RetentionTicker.C:
+			expireMessages(Queue, time.Now())
+		case event := <-Queue.SendChannel:
+			sendMessage(Queue, event)
+			serveReceiveWaiters(Queues, Queue)
+		case event := <-Queue.RedriveChannel:
+			acceptDeadLetterMessage(Queue, event)
+			serveReceiveWaiters(Queues, Queue)
 		case event := <-Queue.ReceiveChannel:
-			receiveMessage(Queue, event)
+			receiveMessage(Queues, Queue, event)
 		case event := <-Queue.DeleteChannel:
 			deleteMessage(Queue, event)
+		case event := <-Queue.ChangeVisibilityChannel:
+			changeMessageVisibility(Queue, event, time.Now().Unix())
+		case event := <-Queue.PurgeChannel:
+			purgeQueue(Queue, event)
+		case event := <-Queue.GetAttributesChannel:
+			getQueueAttributes(Queue, event)
+		case event := <-Queue.SetAttributesChannel:
+			setQueueAttributes(Queue, event)
+		case event := <-Queue.TagChannel:
+			tagQueue(Queue, event)
+		case event := <-Queue.PermissionChannel:
+			changePermission(Queue, event)
 		}
 	}
 }
 
-func sendMessage(Queue *queue.Queue, Message *queue.Message) {
-	// TODO: Check if there's a race condition with reading this field somewhere else
-	Queue.ApproximateNumberOfMessages++
-	Queue.Messages2[Message.MessageID] = Message
+func sendMessage(Queue *queue.Queue, Event events.SendRequestEvent) {
+	var Message = Event.Message.(*queue.Message)
+	if Event.MessageSize > Queue.MaximumMessageSize {
+		Event.ReturnChan <- events.SendResponseEvent{
+			Ok:           false,
+			ErrorCode:    "InvalidParameterValue",
+			ErrorMessage: fmt.Sprintf("One or more parameters are invalid. Reason: Message must be shorter than %d bytes.", Queue.MaximumMessageSize),
+		}
+		return
+	}
+	if SendResponseEvent, ok := validateFifoParameters(Queue, Message, Event.DelaySeconds); !ok {
+		Event.ReturnChan <- SendResponseEvent
+		return
+	}
+
+	var DelaySeconds = Event.DelaySeconds
+	if DelaySeconds == events.QueueDelaySeconds {
+		DelaySeconds = Queue.DelaySeconds
+	}
+	if DelaySeconds > 0 {
+		Message.VisibilityDeadline = time.Now().Unix() + int64(DelaySeconds)
+	}
+
+	if Queue.FifoQueue {
+		var SendResponseEvent, ok = deduplicateFifoMessage(Queue, Message)
+		if !ok {
+			Event.ReturnChan <- SendResponseEvent
+			return
+		}
+	}
+
+	storeMessage(Queue, Message)
+	Event.ReturnChan <- events.SendResponseEvent{
+		Ok:             true,
+		MessageID:      Message.MessageID,
+		SequenceNumber: Message.SequenceNumber,
+	}
 }
 
-func receiveMessage(Queue *queue.Queue, Event events.ReceiveRequestEvent) {
-	var Now = time.Now().Unix()
-	var FoundMessages = make([]interface{}, limits.MaxBatchSize)
-	var NumFoundMessages = 0
-	for _, Message := range Queue.Messages2 {
-		if Message.VisibilityDeadline < Now {
-			FoundMessages[NumFoundMessages] = Message
-
-			if Message.ReceiptHandle != "" {
-				Queue.ReceiptHandles.Delete(Message.ReceiptHandle)
+func receiveMessage(Queues *queuemgr.Manager, Queue *queue.Queue, Event events.ReceiveRequestEvent) {
+	if Event.VisibilityTimeout == events.QueueVisibilityTimeout {
+		Event.VisibilityTimeout = Queue.VisibilityTimeout
+	}
+	if Event.WaitTimeSeconds == events.QueueWaitTimeSeconds {
+		Event.WaitTimeSeconds = Queue.ReceiveMessageWaitTimeSeconds
+	}
+
+	var FoundMessages = collectMessages(Queues, Queue, Event.MaxNumberOfMessages, Event.VisibilityTimeout, time.Now().Unix())
+	if len(FoundMessages) > 0 || Event.WaitTimeSeconds == 0 {
+		Event.ReturnChan <- events.ReceiveResponseEvent{
+			Messages: FoundMessages,
+		}
+		return
+	}
+
+	Queue.ReceiveWaiters = append(Queue.ReceiveWaiters, queue.ReceiveWaiter{
+		Event:        Event,
+		WaitDeadline: time.Now().Add(time.Duration(Event.WaitTimeSeconds) * time.Second),
+	})
+}
+
+// serveReceiveWaiters fulfills parked receive requests in order of their arrival,
+// completes expired ones with no messages and drops ones abandoned by clients.
+func serveReceiveWaiters(Queues *queuemgr.Manager, Queue *queue.Queue) {
+	if len(Queue.ReceiveWaiters) == 0 {
+		return
+	}
+
+	var Now = time.Now()
+	var HasVisibleMessages = true
+	var RemainingWaiters = Queue.ReceiveWaiters[:0]
+	for _, Waiter := range Queue.ReceiveWaiters {
+		select {
+		case <-Waiter.Event.Done:
+			continue
+		default:
+		}
+
+		if HasVisibleMessages {
+			var FoundMessages = collectMessages(Queues, Queue, Waiter.Event.MaxNumberOfMessages, Waiter.Event.VisibilityTimeout, Now.Unix())
+			if len(FoundMessages) > 0 {
+				Waiter.Event.ReturnChan <- events.ReceiveResponseEvent{
+					Messages: FoundMessages,
+				}
+				continue
 			}
-			// TODO: Refactor to put both message ID and unique receipt handle inside
-			// eliminate the need for separate map for receipt handles
-			Message.ReceiptHandle = uuid.Must(uuid.NewV4()).String()
-			Queue.ReceiptHandles.Store(Message.ReceiptHandle, Message)
-			Message.VisibilityDeadline = Now + int64(Event.VisibilityTimeout)
-			if Message.ApproximateFirstReceiveTimestamp == 0 {
-				Message.ApproximateFirstReceiveTimestamp = time.Now().Unix()
+			HasVisibleMessages = false
+		}
+
+		if !Now.Before(Waiter.WaitDeadline) {
+			Waiter.Event.ReturnChan <- events.ReceiveResponseEvent{
+				Messages: []interface{}{},
 			}
-			Message.ApproximateReceiveCount++
+			continue
+		}
+
+		RemainingWaiters = append(RemainingWaiters, Waiter)
+	}
 
-			NumFoundMessages++
-			if NumFoundMessages == Event.MaxNumberOfMessages {
+	for i := len(RemainingWaiters); i < len(Queue.ReceiveWaiters); i++ {
+		Queue.ReceiveWaiters[i] = queue.ReceiveWaiter{}
+	}
+	Queue.ReceiveWaiters = RemainingWaiters
+}
+
+func collectMessages(Queues *queuemgr.Manager, Queue *queue.Queue, MaxNumberOfMessages int, VisibilityTimeout int, Now int64) []interface{} {
+	promoteMessages(Queue, Now)
+	var FoundMessages []interface{}
+	if Queue.FifoQueue {
+		FoundMessages = collectFifoMessages(Queues, Queue, MaxNumberOfMessages, VisibilityTimeout, Now)
+	} else {
+		FoundMessages = make([]interface{}, 0, MaxNumberOfMessages)
+		for len(FoundMessages) < MaxNumberOfMessages {
+			var Message = Queue.VisibleMessages.Peek()
+			if Message == nil {
 				break
 			}
+			if Queue.RedrivePolicy != nil && Message.ApproximateReceiveCount >= Queue.RedrivePolicy.MaxReceiveCount {
+				moveToDeadLetterQueue(Queues, Queue, Message)
+				continue
+			}
+
+			markMessageReceived(Queue, Message, Now, VisibilityTimeout)
+			FoundMessages = append(FoundMessages, Message)
 		}
 	}
 
-	Event.ReturnChan <- events.ReceiveResponseEvent{
-		Messages: FoundMessages[:NumFoundMessages],
+	// Received messages are attached back only once all of them are collected, otherwise messages
+	// received with visibility timeout of 0 would stay visible and be received again by the same request.
+	for _, Message := range FoundMessages {
+		attachMessage(Queue, Message.(*queue.Message), Now)
+	}
+
+	return FoundMessages
+}
+
+// storeMessage adds a message to a queue and to its indexes.
+func storeMessage(Queue *queue.Queue, Message *queue.Message) {
+	Queue.Messages2[Message.MessageID] = Message
+	Queue.MessagesBySentTimestamp.Push(Message)
+	if Queue.FifoQueue {
+		Queue.MessageGroups.Push(Message)
 	}
+	attachMessage(Queue, Message, time.Now().Unix())
+}
+
+// removeMessage removes a message from a queue, its indexes and its receipt handle.
+func removeMessage(Queue *queue.Queue, Message *queue.Message) {
+	if Message.ReceiptHandle != "" {
+		Queue.ReceiptHandles.Delete(Message.ReceiptHandle)
+	}
+	delete(Queue.Messages2, Message.MessageID)
+	Queue.MessagesBySentTimestamp.Remove(Message)
+	detachMessage(Queue, Message)
+	if Queue.FifoQueue {
+		Queue.MessageGroups.Remove(Message)
+	}
+}
+
+// attachMessage adds a message to visible or invisible messages according to its visibility deadline
+// and counts it. Invisible message is delayed if it was never received, and is in flight otherwise.
+// Message group of a FIFO queue becomes ready when its first message becomes visible.
+// Deadlines are in whole seconds, so a message is visible in the second of its deadline,
+// otherwise visibility timeout of 0 would keep a message in flight for up to a second.
+func attachMessage(Queue *queue.Queue, Message *queue.Message, Now int64) {
+	if Message.VisibilityDeadline <= Now {
+		Queue.VisibleMessages.Push(Message)
+		Queue.ApproximateNumberOfMessages++
+	} else {
+		Queue.InvisibleMessages.Push(Message)
+		if Message.ApproximateReceiveCount == 0 {
+			Queue.ApproximateNumberOfMessagesDelayed++
+		} else {
+			Queue.ApproximateNumberOfMessagesNotVisible++
+		}
+	}
+
+	if Queue.FifoQueue {
+		Queue.MessageGroups.Update(Message)
+	}
+}
+
+// detachMessage removes a message from visible or invisible messages, so that its visibility
+// deadline or receive count could be changed before it is attached again.
+func detachMessage(Queue *queue.Queue, Message *queue.Message) {
+	if Queue.VisibleMessages.Contains(Message) {
+		Queue.VisibleMessages.Remove(Message)
+		Queue.ApproximateNumberOfMessages--
+	} else if Queue.InvisibleMessages.Contains(Message) {
+		Queue.InvisibleMessages.Remove(Message)
+		if Message.ApproximateReceiveCount == 0 {
+			Queue.ApproximateNumberOfMessagesDelayed--
+		} else {
+			Queue.ApproximateNumberOfMessagesNotVisible--
+		}
+	}
+
+	if Queue.FifoQueue {
+		Queue.MessageGroups.Update(Message)
+	}
+}
+
+// promoteMessages makes visible delayed and in flight messages whose visibility deadline has come.
+func promoteMessages(Queue *queue.Queue, Now int64) {
+	for {
+		var Message = Queue.InvisibleMessages.Peek()
+		if Message == nil || Message.VisibilityDeadline > Now {
+			return
+		}
+		detachMessage(Queue, Message)
+		attachMessage(Queue, Message, Now)
+	}
+}
+
+// markMessageReceived detaches a message and assigns it a new receipt handle and visibility deadline,
+// the message should be attached back once the receive request is served.
+func markMessageReceived(Queue *queue.Queue, Message *queue.Message, Now int64, VisibilityTimeout int) {
+	detachMessage(Queue, Message)
+	if Message.ReceiptHandle != "" {
+		Queue.ReceiptHandles.Delete(Message.ReceiptHandle)
+	}
+	// TODO: Refactor to put both message ID and unique receipt handle inside
+	// eliminate the need for separate map for receipt handles
+	Message.ReceiptHandle = uuid.Must(uuid.NewV4()).String()
+	Queue.ReceiptHandles.Store(Message.ReceiptHandle, Message)
+	Message.VisibilityDeadline = Now + int64(VisibilityTimeout)
+	if Message.ApproximateFirstReceiveTimestamp == 0 {
+		Message.ApproximateFirstReceiveTimestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	Message.ApproximateReceiveCount++
 }
 
 func deleteMessage(Queue *queue.Queue, Event events.DeleteRequestEvent) {
@@ -124,11 +457,159 @@ func deleteMessage(Queue *queue.Queue, Event events.DeleteRequestEvent) {
 		return
 	}
 
-	var Message = MessagePtr.(*queue.Message)
-	Queue.ReceiptHandles.Delete(Event.ReceiptHandle)
-	delete(Queue.Messages2, Message.MessageID)
-	Queue.ApproximateNumberOfMessages--
+	removeMessage(Queue, MessagePtr.(*queue.Message))
 	Event.ReturnChan <- events.DeleteResponseEvent{
 		Ok: true,
 	}
 }
+
+func changeMessageVisibility(Queue *queue.Queue, Event events.ChangeVisibilityRequestEvent, Now int64) {
+	var MessagePtr, ok = Queue.ReceiptHandles.Load(Event.ReceiptHandle)
+	if !ok {
+		Event.ReturnChan <- events.ChangeVisibilityResponseEvent{
+			Ok:           false,
+			ErrorCode:    "ReceiptHandleIsInvalid",
+			ErrorMessage: fmt.Sprintf("The input receipt handle \"%s\" is not a valid receipt handle.", Event.ReceiptHandle),
+		}
+		return
+	}
+
+	var Message = MessagePtr.(*queue.Message)
+	if Message.VisibilityDeadline <= Now {
+		Event.ReturnChan <- events.ChangeVisibilityResponseEvent{
+			Ok:           false,
+			ErrorCode:    "AWS.SimpleQueueService.MessageNotInflight",
+			ErrorMessage: "The specified message isn't in flight.",
+		}
+		return
+	}
+
+	detachMessage(Queue, Message)
+	Message.VisibilityDeadline = Now + int64(Event.VisibilityTimeout)
+	attachMessage(Queue, Message, Now)
+	Event.ReturnChan <- events.ChangeVisibilityResponseEvent{
+		Ok: true,
+	}
+}
+
+func purgeQueue(Queue *queue.Queue, Event events.PurgeRequestEvent) {
+	var Now = time.Now().Unix()
+	if Queue.LastPurgedTimestamp != 0 && Now-Queue.LastPurgedTimestamp < limits.PurgeQueueInterval {
+		Event.ReturnChan <- events.PurgeResponseEvent{
+			Ok: false,
+		}
+		return
+	}
+
+	Queue.ReceiptHandles.Range(func(ReceiptHandle, _ interface{}) bool {
+		Queue.ReceiptHandles.Delete(ReceiptHandle)
+		return true
+	})
+	Queue.Messages2 = make(map[string]*queue.Message)
+	Queue.VisibleMessages = queue.NewVisibleHeap()
+	Queue.InvisibleMessages = queue.NewInvisibleHeap()
+	Queue.MessagesBySentTimestamp = queue.NewRetentionHeap()
+	if Queue.FifoQueue {
+		Queue.MessageGroups = queue.NewMessageGroups(Queue.VisibleMessages)
+	}
+	Queue.ApproximateNumberOfMessages = 0
+	Queue.ApproximateNumberOfMessagesNotVisible = 0
+	Queue.ApproximateNumberOfMessagesDelayed = 0
+	Queue.LastPurgedTimestamp = Now
+	Event.ReturnChan <- events.PurgeResponseEvent{
+		Ok: true,
+	}
+}
+
+// expireMessages removes messages which were kept in the queue for longer than its retention period,
+// including ones that are in flight.
+func expireMessages(Queue *queue.Queue, Now time.Time) {
+	var ExpiredSentTimestamp = Now.Add(-time.Duration(Queue.MessageRetentionPeriod)*time.Second).UnixNano() / int64(time.Millisecond)
+	for {
+		var Message = Queue.MessagesBySentTimestamp.Peek()
+		if Message == nil || Message.SentTimestamp > ExpiredSentTimestamp {
+			return
+		}
+		removeMessage(Queue, Message)
+	}
+}
+
+// queueAttributeNames lists attributes which could be set on a queue.
+var queueAttributeNames = []string{
+	"ContentBasedDeduplication",
+	"DeduplicationScope",
+	"DelaySeconds",
+	"FifoQueue",
+	"FifoThroughputLimit",
+	"KmsDataKeyReusePeriodSeconds",
+	"KmsMasterKeyId",
+	"MaximumMessageSize",
+	"MessageRetentionPeriod",
+	"Policy",
+	"ReceiveMessageWaitTimeSeconds",
+	"RedriveAllowPolicy",
+	"RedrivePolicy",
+	"SqsManagedSseEnabled",
+	"VisibilityTimeout",
+}
+
+func getQueueAttributes(Queue *queue.Queue, Event events.GetAttributesRequestEvent) {
+	promoteMessages(Queue, time.Now().Unix())
+
+	var Attributes = map[string]string{
+		"QueueArn":                              Queue.QueueArn,
+		"ApproximateNumberOfMessages":           strconv.FormatInt(Queue.ApproximateNumberOfMessages, 10),
+		"ApproximateNumberOfMessagesNotVisible": strconv.FormatInt(Queue.ApproximateNumberOfMessagesNotVisible, 10),
+		"ApproximateNumberOfMessagesDelayed":    strconv.FormatInt(Queue.ApproximateNumberOfMessagesDelayed, 10),
+		"CreatedTimestamp":                      strconv.FormatInt(Queue.CreatedTimestamp, 10),
+		"LastModifiedTimestamp":                 strconv.FormatInt(Queue.LastModifiedTimestamp, 10),
+	}
+	for _, Name := range queueAttributeNames {
+		Attributes[Name] = GetQueueAttribute(Queue, Name)
+	}
+
+	Event.ReturnChan <- events.GetAttributesResponseEvent{
+		Attributes: Attributes,
+	}
+}
+
+func setQueueAttributes(Queue *queue.Queue, Event events.SetAttributesRequestEvent) {
+	applyQueueAttributes(Queue, Event.Attributes)
+	Queue.LastModifiedTimestamp = time.Now().Unix()
+	Event.ReturnChan <- events.SetAttributesResponseEvent{
+		Ok: true,
+	}
+}
+
+func tagQueue(Queue *queue.Queue, Event events.TagRequestEvent) {
+	var NumNewTags = 0
+	for Key := range Event.AddTags {
+		if _, ok := Queue.Tags[Key]; !ok {
+			NumNewTags++
+		}
+	}
+	if len(Queue.Tags)+NumNewTags > limits.MaxQueueTags {
+		Event.ReturnChan <- events.TagResponseEvent{
+			Ok:           false,
+			ErrorCode:    "InvalidParameterValue",
+			ErrorMessage: fmt.Sprintf("Too many tags added for queue %s.", Queue.QueueName),
+		}
+		return
+	}
+
+	for _, Key := range Event.RemoveTagKeys {
+		delete(Queue.Tags, Key)
+	}
+	for Key, Value := range Event.AddTags {
+		Queue.Tags[Key] = Value
+	}
+
+	var Tags = make(map[string]string, len(Queue.Tags))
+	for Key, Value := range Queue.Tags {
+		Tags[Key] = Value
+	}
+	Event.ReturnChan <- events.TagResponseEvent{
+		Ok:   true,
+		Tags: Tags,
+	}
+}
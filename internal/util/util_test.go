@@ -0,0 +1,197 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andreyst/go-sqs/internal/events"
+	"github.com/andreyst/go-sqs/internal/queue"
+	uuid "github.com/satori/go.uuid"
+)
+
+func newTestMessage(SentAt time.Time) *queue.Message {
+	return &queue.Message{
+		MessageID:     uuid.Must(uuid.NewV4()).String(),
+		Body:          "test",
+		SentTimestamp: SentAt.UnixNano() / int64(time.Millisecond),
+	}
+}
+
+func assertCounters(t *testing.T, Queue *queue.Queue, Visible int64, NotVisible int64, Delayed int64) {
+	t.Helper()
+	if Queue.ApproximateNumberOfMessages != Visible || Queue.ApproximateNumberOfMessagesNotVisible != NotVisible ||
+		Queue.ApproximateNumberOfMessagesDelayed != Delayed {
+		t.Fatalf("expected %d visible, %d not visible and %d delayed messages, got %d, %d and %d",
+			Visible, NotVisible, Delayed, Queue.ApproximateNumberOfMessages,
+			Queue.ApproximateNumberOfMessagesNotVisible, Queue.ApproximateNumberOfMessagesDelayed)
+	}
+}
+
+func TestExpireMessages(t *testing.T) {
+	var Queue = newQueue("000000000000", "us-east-1", "test", map[string]string{"MessageRetentionPeriod": "60"}, nil)
+	var Now = time.Now()
+	var SentAt = Now.Add(-90 * time.Second)
+
+	storeMessage(Queue, newTestMessage(SentAt))
+	var InFlightMessage = newTestMessage(SentAt)
+	storeMessage(Queue, InFlightMessage)
+	markMessageReceived(Queue, InFlightMessage, Now.Unix(), 30)
+	attachMessage(Queue, InFlightMessage, Now.Unix())
+	var DelayedMessage = newTestMessage(SentAt)
+	DelayedMessage.VisibilityDeadline = Now.Unix() + 60
+	storeMessage(Queue, DelayedMessage)
+	var FreshMessage = newTestMessage(Now)
+	storeMessage(Queue, FreshMessage)
+	assertCounters(t, Queue, 2, 1, 1)
+
+	expireMessages(Queue, Now)
+	assertCounters(t, Queue, 1, 0, 0)
+	if len(Queue.Messages2) != 1 || Queue.Messages2[FreshMessage.MessageID] != FreshMessage {
+		t.Fatalf("expected only the fresh message to be kept, got %d messages", len(Queue.Messages2))
+	}
+	if _, ok := Queue.ReceiptHandles.Load(InFlightMessage.ReceiptHandle); ok {
+		t.Fatal("expected receipt handle of the expired in flight message to be removed")
+	}
+	if Queue.InvisibleMessages.Len() != 0 || Queue.MessagesBySentTimestamp.Len() != 1 {
+		t.Fatalf("expected expired messages to be removed from heaps, got %d invisible and %d retained",
+			Queue.InvisibleMessages.Len(), Queue.MessagesBySentTimestamp.Len())
+	}
+
+	expireMessages(Queue, Now.Add(61*time.Second))
+	assertCounters(t, Queue, 0, 0, 0)
+	if len(Queue.Messages2) != 0 || Queue.VisibleMessages.Len() != 0 {
+		t.Fatalf("expected all messages to expire, got %d", len(Queue.Messages2))
+	}
+}
+
+func TestChangeMessageVisibilityToZero(t *testing.T) {
+	var Queue = newQueue("000000000000", "us-east-1", "test", nil, nil)
+	var Now = time.Now().Unix()
+	var Message = newTestMessage(time.Now())
+	storeMessage(Queue, Message)
+	if FoundMessages := collectMessages(nil, Queue, 10, 30, Now); len(FoundMessages) != 1 {
+		t.Fatalf("expected a message to be received, got %d", len(FoundMessages))
+	}
+
+	// Message is released within the same second, as workers do to hand it over right away
+	var ReturnChan = make(chan events.ChangeVisibilityResponseEvent, 1)
+	changeMessageVisibility(Queue, events.ChangeVisibilityRequestEvent{
+		ReceiptHandle:     Message.ReceiptHandle,
+		VisibilityTimeout: 0,
+		ReturnChan:        ReturnChan,
+	}, Now)
+	if ChangeVisibilityResponseEvent := <-ReturnChan; !ChangeVisibilityResponseEvent.Ok {
+		t.Fatal(ChangeVisibilityResponseEvent.ErrorMessage)
+	}
+	assertCounters(t, Queue, 1, 0, 0)
+
+	var FoundMessages = collectMessages(nil, Queue, 10, 30, Now)
+	if len(FoundMessages) != 1 || FoundMessages[0] != Message || Message.ApproximateReceiveCount != 2 {
+		t.Fatalf("expected the released message to be received again, got %d messages", len(FoundMessages))
+	}
+}
+
+func receiveTestMessages(t *testing.T, Queue *queue.Queue, VisibilityTimeout int) []interface{} {
+	t.Helper()
+	var ReturnChan = make(chan events.ReceiveResponseEvent, 1)
+	receiveMessage(nil, Queue, events.ReceiveRequestEvent{
+		MaxNumberOfMessages: 10,
+		VisibilityTimeout:   VisibilityTimeout,
+		ReturnChan:          ReturnChan,
+	})
+	return (<-ReturnChan).Messages
+}
+
+func TestReceiveMessageWithVisibilityTimeoutZero(t *testing.T) {
+	for _, Case := range []struct {
+		QueueVisibilityTimeout string
+		VisibilityTimeout      int
+	}{
+		{"0", events.QueueVisibilityTimeout},
+		{"30", 0},
+	} {
+		var Queue = newQueue("000000000000", "us-east-1", "test", map[string]string{"VisibilityTimeout": Case.QueueVisibilityTimeout}, nil)
+		var Message = newTestMessage(time.Now())
+		storeMessage(Queue, Message)
+
+		// Message is received once per request and stays visible right away
+		for ReceiveCount := 1; ReceiveCount <= 2; ReceiveCount++ {
+			var FoundMessages = receiveTestMessages(t, Queue, Case.VisibilityTimeout)
+			if len(FoundMessages) != 1 || FoundMessages[0] != Message || Message.ApproximateReceiveCount != ReceiveCount {
+				t.Fatalf("expected the message to be received %d times with %+v, got %d messages", ReceiveCount, Case, len(FoundMessages))
+			}
+			assertCounters(t, Queue, 1, 0, 0)
+		}
+	}
+}
+
+// benchmarkBacklog is a number of messages kept in a queue by benchmarks.
+const benchmarkBacklog = 1000000
+
+func newBacklogQueue(b *testing.B, NumMessages int) *queue.Queue {
+	var Queue = newQueue("000000000000", "us-east-1", "test", nil, nil)
+	for i := 0; i < NumMessages; i++ {
+		sendTestMessage(b, Queue, newTestMessage(time.Now()))
+	}
+	return Queue
+}
+
+func BenchmarkSendMessageBacklog(b *testing.B) {
+	var Queue = newBacklogQueue(b, benchmarkBacklog)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sendTestMessage(b, Queue, newTestMessage(time.Now()))
+	}
+}
+
+func BenchmarkReceiveMessageBacklog(b *testing.B) {
+	var Queue = newBacklogQueue(b, benchmarkBacklog)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var FoundMessages = collectMessages(nil, Queue, 10, 30, time.Now().Unix())
+		if len(FoundMessages) == 0 {
+			b.StopTimer()
+			for j := 0; j < benchmarkBacklog; j++ {
+				sendTestMessage(b, Queue, newTestMessage(time.Now()))
+			}
+			b.StartTimer()
+		}
+		for _, Message := range FoundMessages {
+			removeMessage(Queue, Message.(*queue.Message))
+		}
+	}
+}
+
+// BenchmarkReceiveMessageInvisibleBacklog measures receive from a queue whose backlog is delayed,
+// so that there is nothing to receive among a deep backlog of messages.
+func BenchmarkReceiveMessageInvisibleBacklog(b *testing.B) {
+	var Queue = newQueue("000000000000", "us-east-1", "test", map[string]string{"DelaySeconds": "900"}, nil)
+	for i := 0; i < benchmarkBacklog; i++ {
+		sendTestMessage(b, Queue, newTestMessage(time.Now()))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		collectMessages(nil, Queue, 10, 30, time.Now().Unix())
+	}
+}
+
+func BenchmarkChangeMessageVisibilityBacklog(b *testing.B) {
+	var Queue = newBacklogQueue(b, benchmarkBacklog)
+	var FoundMessages = collectMessages(nil, Queue, 10, 30, time.Now().Unix())
+	var ReturnChan = make(chan events.ChangeVisibilityResponseEvent, 1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		changeMessageVisibility(Queue, events.ChangeVisibilityRequestEvent{
+			ReceiptHandle:     FoundMessages[i%len(FoundMessages)].(*queue.Message).ReceiptHandle,
+			VisibilityTimeout: 30 + i%60,
+			ReturnChan:        ReturnChan,
+		}, time.Now().Unix())
+		if ChangeVisibilityResponseEvent := <-ReturnChan; !ChangeVisibilityResponseEvent.Ok {
+			b.Fatal(ChangeVisibilityResponseEvent.ErrorMessage)
+		}
+	}
+}
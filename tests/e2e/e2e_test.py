@@ -1,5 +1,6 @@
 import boto3
 import botocore
+import json
 import logging
 import sys
 import pytest
@@ -8,12 +9,17 @@ import time
 import math
 import subprocess
 import tempfile
+import threading
+import urllib.error
+import urllib.parse
+import urllib.request
 
 PORT = os.environ.get("PORT", "23782")
 
 session = boto3.session.Session()
+# Read timeout exceeds the longest receive wait time of 20 seconds
 config = botocore.client.Config(
-    connect_timeout=3, read_timeout=3, retries={"max_attempts": 0}
+    connect_timeout=3, read_timeout=25, retries={"max_attempts": 0}
 )
 sqs_client = session.client(
     service_name="sqs",
@@ -74,9 +80,8 @@ def create_random_queue():
 
     def _create_random_queue(queue_name="", cleanup=True):
         if queue_name == "":
-            queue_name = "{}_{}".format(
-                create_queue_name_prefix(), str(len(created_queues))
-            )
+            # Names of deleted queues could not be reused for a while
+            queue_name = "{}_{}".format(create_queue_name_prefix(), time.time_ns())
         res = sqs_client.create_queue(QueueName=queue_name)
         queue_url = res["QueueUrl"]
         created_queues.append({"QueueUrl": queue_url, "Cleanup": cleanup})
@@ -168,12 +173,84 @@ def test_get_queue_url(create_random_queue):
     assert res["QueueUrl"] == queue_url
 
 
+def test_list_queues_pagination(create_random_queue):
+    prefix = create_queue_name_prefix() + "_list_"
+    queue_urls = sorted(create_random_queue(prefix + str(i))[1] for i in range(3))
+
+    res = sqs_client.list_queues(QueueNamePrefix=prefix)
+    assert res["QueueUrls"] == queue_urls
+    assert "NextToken" not in res
+
+    res = sqs_client.list_queues(QueueNamePrefix=prefix, MaxResults=2)
+    assert res["QueueUrls"] == queue_urls[:2]
+    res = sqs_client.list_queues(
+        QueueNamePrefix=prefix, MaxResults=2, NextToken=res["NextToken"]
+    )
+    assert res["QueueUrls"] == queue_urls[2:]
+    assert "NextToken" not in res
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.list_queues(MaxResults=1001)
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def test_queue_url_and_arn(create_random_queue):
+    queue_name, queue_url = create_random_queue()
+    assert queue_url == "http://localhost:%s/000000000000/%s" % (PORT, queue_name)
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=queue_url, AttributeNames=["QueueArn"]
+    )
+    assert res["Attributes"]["QueueArn"] == "arn:aws:sqs:us-east-1:000000000000:" + queue_name
+
+    sqs_client.send_message(QueueUrl="http://example.com/" + queue_name, MessageBody="123")
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.send_message(
+            QueueUrl="http://localhost/111111111111/" + queue_name, MessageBody="123"
+        )
+    assert "NonExistentQueue" in str(exinfo.value)
+
+
 def test_get_queue_attributes(create_random_queue):
     _, queue_url = create_random_queue()
     # TODO: Actually check some of the returned values
     sqs_client.get_queue_attributes(QueueUrl=queue_url)
 
 
+def test_get_queue_attributes_by_name(create_random_queue):
+    _, queue_url = create_random_queue()
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=queue_url, AttributeNames=["DelaySeconds", "SqsManagedSseEnabled"]
+    )
+    assert res["Attributes"] == {"DelaySeconds": "0", "SqsManagedSseEnabled": "true"}
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    assert res["Attributes"]["VisibilityTimeout"] == "30"
+    assert "FifoQueue" not in res["Attributes"]
+    assert "KmsMasterKeyId" not in res["Attributes"]
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["Foo"])
+    assert "InvalidAttributeName" in str(exinfo.value)
+
+
+def test_get_queue_attributes_encryption(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.set_queue_attributes(
+        QueueUrl=queue_url, Attributes={"KmsMasterKeyId": "alias/aws/sqs"}
+    )
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    assert res["Attributes"]["KmsMasterKeyId"] == "alias/aws/sqs"
+    assert res["Attributes"]["KmsDataKeyReusePeriodSeconds"] == "300"
+    assert res["Attributes"]["SqsManagedSseEnabled"] == "false"
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.set_queue_attributes(
+            QueueUrl=queue_url,
+            Attributes={"KmsMasterKeyId": "alias/aws/sqs", "SqsManagedSseEnabled": "true"},
+        )
+    assert "InvalidAttributeValue" in str(exinfo.value)
+
+
 def test_send_message(create_random_queue):
     _, queue_url = create_random_queue()
     sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
@@ -220,6 +297,14 @@ def test_empty_receive_message(create_random_queue):
     assert "Messages" not in res
 
 
+def test_empty_receive_message_waits(create_random_queue):
+    _, queue_url = create_random_queue()
+    started_at = time.time()
+    res = sqs_client.receive_message(QueueUrl=queue_url, WaitTimeSeconds=1)
+    assert "Messages" not in res
+    assert time.time() - started_at >= 1
+
+
 def test_exactly_once_send_receive():
     # TODO: Add a multiprocessing test to write and read 100k messages simultaneously and
     # verify that everything that was sent was received exactly once
@@ -236,6 +321,29 @@ def test_receive_message_delay_seconds(create_random_queue):
     assert len(res["Messages"]) == 1
 
 
+def test_message_counters(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="234")
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="345", DelaySeconds=60)
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url, WaitTimeSeconds=0, VisibilityTimeout=60
+    )
+    assert len(res["Messages"]) == 1
+    receipt_handle = res["Messages"][0]["ReceiptHandle"]
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    assert res["Attributes"]["ApproximateNumberOfMessages"] == "1"
+    assert res["Attributes"]["ApproximateNumberOfMessagesNotVisible"] == "1"
+    assert res["Attributes"]["ApproximateNumberOfMessagesDelayed"] == "1"
+
+    sqs_client.delete_message(QueueUrl=queue_url, ReceiptHandle=receipt_handle)
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    assert res["Attributes"]["ApproximateNumberOfMessages"] == "1"
+    assert res["Attributes"]["ApproximateNumberOfMessagesNotVisible"] == "0"
+    assert res["Attributes"]["ApproximateNumberOfMessagesDelayed"] == "1"
+
+
 def test_receive_message_invalid_parameters(create_random_queue):
     _, queue_url = create_random_queue()
 
@@ -321,7 +429,768 @@ def test_delete_queue(create_random_queue):
     sqs_client.delete_queue(QueueUrl=queue_url)
 
 
+def test_delete_queue_fails_pending_requests(create_random_queue):
+    queue_name, queue_url = create_random_queue(cleanup=False)
+    errors = []
+
+    def receive():
+        try:
+            sqs_client.receive_message(QueueUrl=queue_url, WaitTimeSeconds=2)
+        except botocore.exceptions.ClientError as e:
+            errors.append(str(e))
+
+    receiver = threading.Thread(target=receive)
+    receiver.start()
+    time.sleep(0.5)
+    sqs_client.delete_queue(QueueUrl=queue_url)
+    receiver.join()
+    assert len(errors) == 1 and "NonExistentQueue" in errors[0]
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    assert "NonExistentQueue" in str(exinfo.value)
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.create_queue(QueueName=queue_name)
+    assert "QueueDeletedRecently" in str(exinfo.value)
+
+
 def test_delete_queue_nonexistent():
     with pytest.raises(botocore.exceptions.ClientError) as exinfo:
         sqs_client.delete_queue(QueueUrl="nonexistent-queue")
     assert "AWS.SimpleQueueService.NonExistentQueue" in str(exinfo.value)
+
+
+def test_change_message_visibility(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    res = sqs_client.receive_message(QueueUrl=queue_url, VisibilityTimeout=30)
+    receipt_handle = res["Messages"][0]["ReceiptHandle"]
+
+    sqs_client.change_message_visibility(
+        QueueUrl=queue_url, ReceiptHandle=receipt_handle, VisibilityTimeout=0
+    )
+    time.sleep(1.1)
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert len(res["Messages"]) == 1
+
+
+def test_change_message_visibility_bad_receipt_handle(create_random_queue):
+    _, queue_url = create_random_queue()
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.change_message_visibility(
+            QueueUrl=queue_url,
+            ReceiptHandle="fake-receipt-handle",
+            VisibilityTimeout=10,
+        )
+    assert "ReceiptHandleIsInvalid" in str(exinfo.value)
+
+
+def test_change_message_visibility_batch(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    res = sqs_client.change_message_visibility_batch(
+        QueueUrl=queue_url,
+        Entries=[
+            {
+                "Id": "1",
+                "ReceiptHandle": res["Messages"][0]["ReceiptHandle"],
+                "VisibilityTimeout": 60,
+            },
+            {"Id": "2", "ReceiptHandle": "fake-receipt-handle", "VisibilityTimeout": 60},
+        ],
+    )
+    assert len(res["Successful"]) == 1
+    assert res["Successful"][0]["Id"] == "1"
+    assert len(res["Failed"]) == 1
+    assert res["Failed"][0]["Code"] == "ReceiptHandleIsInvalid"
+
+
+def test_purge_queue(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="234")
+    sqs_client.purge_queue(QueueUrl=queue_url)
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url)
+    assert res["Attributes"]["ApproximateNumberOfMessages"] == "0"
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert "Messages" not in res
+
+
+def test_purge_queue_in_progress(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.purge_queue(QueueUrl=queue_url)
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.purge_queue(QueueUrl=queue_url)
+    assert "PurgeQueueInProgress" in str(exinfo.value)
+
+
+def test_create_queue_with_attributes(create_random_queue):
+    queue_name = "{}_attributes".format(create_queue_name_prefix())
+    res = sqs_client.create_queue(
+        QueueName=queue_name,
+        Attributes={"VisibilityTimeout": "45", "DelaySeconds": "5"},
+    )
+    queue_url = res["QueueUrl"]
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url)
+    assert res["Attributes"]["VisibilityTimeout"] == "45"
+    assert res["Attributes"]["DelaySeconds"] == "5"
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.create_queue(
+            QueueName=queue_name, Attributes={"VisibilityTimeout": "46"}
+        )
+    assert "QueueAlreadyExists" in str(exinfo.value)
+
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_create_queue_invalid_attribute():
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.create_queue(
+            QueueName="{}_invalid_attribute".format(create_queue_name_prefix()),
+            Attributes={"DelaySeconds": "901"},
+        )
+    assert "InvalidAttributeValue" in str(exinfo.value)
+
+
+def test_set_queue_attributes(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.set_queue_attributes(
+        QueueUrl=queue_url, Attributes={"MaximumMessageSize": "2048"}
+    )
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url)
+    assert res["Attributes"]["MaximumMessageSize"] == "2048"
+
+
+def test_receive_message_queue_visibility_timeout():
+    res = sqs_client.create_queue(
+        QueueName="{}_visibility_timeout".format(create_queue_name_prefix()),
+        Attributes={"VisibilityTimeout": "1"},
+    )
+    queue_url = res["QueueUrl"]
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert len(res["Messages"]) == 1
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert "Messages" not in res
+    time.sleep(2.1)
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert len(res["Messages"]) == 1
+
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_receive_message_invalid_visibility_timeout(create_random_queue):
+    _, queue_url = create_random_queue()
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.receive_message(QueueUrl=queue_url, VisibilityTimeout=43201)
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def test_receive_message_long_polling(create_random_queue):
+    _, queue_url = create_random_queue()
+
+    def send_later():
+        time.sleep(1)
+        sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+
+    sender = threading.Thread(target=send_later)
+    sender.start()
+    res = sqs_client.receive_message(QueueUrl=queue_url, WaitTimeSeconds=2)
+    sender.join()
+    assert len(res["Messages"]) == 1
+
+
+def test_receive_message_queue_wait_time_seconds():
+    res = sqs_client.create_queue(
+        QueueName="{}_wait_time_seconds".format(create_queue_name_prefix()),
+        Attributes={"ReceiveMessageWaitTimeSeconds": "1"},
+    )
+    queue_url = res["QueueUrl"]
+
+    started_at = time.time()
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert "Messages" not in res
+    assert time.time() - started_at >= 1
+
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_receive_message_invalid_wait_time_seconds(create_random_queue):
+    _, queue_url = create_random_queue()
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.receive_message(QueueUrl=queue_url, WaitTimeSeconds=21)
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def test_dead_letter_queue(create_random_queue):
+    _, dead_letter_queue_url = create_random_queue()
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=dead_letter_queue_url, AttributeNames=["QueueArn"]
+    )
+    dead_letter_queue_arn = res["Attributes"]["QueueArn"]
+
+    res = sqs_client.create_queue(
+        QueueName="{}_redrive".format(create_queue_name_prefix()),
+        Attributes={
+            "VisibilityTimeout": "0",
+            "RedrivePolicy": json.dumps(
+                {"deadLetterTargetArn": dead_letter_queue_arn, "maxReceiveCount": 1}
+            ),
+        },
+    )
+    queue_url = res["QueueUrl"]
+
+    res = sqs_client.list_dead_letter_source_queues(QueueUrl=dead_letter_queue_url)
+    assert res["queueUrls"] == [queue_url]
+
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert len(res["Messages"]) == 1
+    time.sleep(1.1)
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert "Messages" not in res
+
+    res = sqs_client.receive_message(QueueUrl=dead_letter_queue_url, WaitTimeSeconds=1)
+    assert res["Messages"][0]["Body"] == "123"
+
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_dead_letter_queue_nonexistent_target():
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.create_queue(
+            QueueName="{}_redrive_nonexistent".format(create_queue_name_prefix()),
+            Attributes={
+                "RedrivePolicy": json.dumps(
+                    {"deadLetterTargetArn": "nonexistent-queue", "maxReceiveCount": 1}
+                )
+            },
+        )
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def test_redrive_allow_policy_deny_all(create_random_queue):
+    _, dead_letter_queue_url = create_random_queue()
+    sqs_client.set_queue_attributes(
+        QueueUrl=dead_letter_queue_url,
+        Attributes={"RedriveAllowPolicy": json.dumps({"redrivePermission": "denyAll"})},
+    )
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=dead_letter_queue_url, AttributeNames=["QueueArn"]
+    )
+    dead_letter_queue_arn = res["Attributes"]["QueueArn"]
+
+    _, queue_url = create_random_queue()
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.set_queue_attributes(
+            QueueUrl=queue_url,
+            Attributes={
+                "RedrivePolicy": json.dumps(
+                    {"deadLetterTargetArn": dead_letter_queue_arn, "maxReceiveCount": 1}
+                )
+            },
+        )
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def create_fifo_queue(content_based_deduplication=False):
+    res = sqs_client.create_queue(
+        QueueName="{}_{}.fifo".format(create_queue_name_prefix(), time.time_ns()),
+        Attributes={
+            "FifoQueue": "true",
+            "ContentBasedDeduplication": str(content_based_deduplication).lower(),
+        },
+    )
+    return res["QueueUrl"]
+
+
+def test_create_fifo_queue_bad_name():
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.create_queue(
+            QueueName=create_queue_name_prefix(), Attributes={"FifoQueue": "true"}
+        )
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def test_fifo_queue_requires_message_group_id():
+    queue_url = create_fifo_queue()
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.send_message(
+            QueueUrl=queue_url, MessageBody="123", MessageDeduplicationId="1"
+        )
+    assert "MissingParameter" in str(exinfo.value)
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_fifo_queue_deduplication():
+    queue_url = create_fifo_queue(content_based_deduplication=True)
+    first = sqs_client.send_message(
+        QueueUrl=queue_url, MessageBody="123", MessageGroupId="a"
+    )
+    second = sqs_client.send_message(
+        QueueUrl=queue_url, MessageBody="123", MessageGroupId="a"
+    )
+    assert first["MessageId"] == second["MessageId"]
+    assert first["SequenceNumber"] == second["SequenceNumber"]
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url)
+    assert res["Attributes"]["ApproximateNumberOfMessages"] == "1"
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_fifo_queue_deduplication_scope():
+    queue_name = "test_queue_{}.fifo".format(time.time_ns())
+    res = sqs_client.create_queue(
+        QueueName=queue_name,
+        Attributes={
+            "FifoQueue": "true",
+            "DeduplicationScope": "messageGroup",
+            "FifoThroughputLimit": "perMessageGroupId",
+        },
+    )
+    queue_url = res["QueueUrl"]
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=queue_url, AttributeNames=["DeduplicationScope", "FifoThroughputLimit"]
+    )
+    assert res["Attributes"] == {
+        "DeduplicationScope": "messageGroup",
+        "FifoThroughputLimit": "perMessageGroupId",
+    }
+
+    for group_id in ["1", "2", "1"]:
+        sqs_client.send_message(
+            QueueUrl=queue_url,
+            MessageBody="123",
+            MessageGroupId=group_id,
+            MessageDeduplicationId="123",
+        )
+    res = sqs_client.get_queue_attributes(
+        QueueUrl=queue_url, AttributeNames=["ApproximateNumberOfMessages"]
+    )
+    assert res["Attributes"]["ApproximateNumberOfMessages"] == "2"
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_fifo_queue_message_group_ordering():
+    queue_url = create_fifo_queue()
+    for i in range(3):
+        sqs_client.send_message(
+            QueueUrl=queue_url,
+            MessageBody="a{}".format(i),
+            MessageGroupId="a",
+            MessageDeduplicationId="a{}".format(i),
+        )
+    sqs_client.send_message(
+        QueueUrl=queue_url,
+        MessageBody="b0",
+        MessageGroupId="b",
+        MessageDeduplicationId="b0",
+    )
+
+    res = sqs_client.receive_message(QueueUrl=queue_url, MaxNumberOfMessages=2)
+    assert [m["Body"] for m in res["Messages"]] == ["a0", "a1"]
+
+    # Group "a" is locked while its messages are in flight
+    res = sqs_client.receive_message(QueueUrl=queue_url, MaxNumberOfMessages=10)
+    assert [m["Body"] for m in res["Messages"]] == ["b0"]
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_send_message_md5(create_random_queue):
+    _, queue_url = create_random_queue()
+    res = sqs_client.send_message(
+        QueueUrl=queue_url,
+        MessageBody="123",
+        MessageAttributes={"foo": {"DataType": "String", "StringValue": "bar"}},
+    )
+    assert res["MD5OfMessageBody"] == "202cb962ac59075b964b07152d234b70"
+    assert "MD5OfMessageAttributes" in res
+
+
+def test_receive_message_attributes(create_random_queue):
+    _, queue_url = create_random_queue()
+    message_attributes = {
+        "foo.string": {"DataType": "String", "StringValue": "bar"},
+        "foo.number": {"DataType": "Number.int", "StringValue": "42"},
+        "binary": {"DataType": "Binary", "BinaryValue": b"\x00\x01\x02"},
+    }
+    res = sqs_client.send_message(
+        QueueUrl=queue_url, MessageBody="123", MessageAttributes=message_attributes
+    )
+    md5_of_message_attributes = res["MD5OfMessageAttributes"]
+
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url, MessageAttributeNames=["All"], VisibilityTimeout=0
+    )
+    message = res["Messages"][0]
+    assert message["MessageAttributes"] == message_attributes
+    assert message["MD5OfMessageAttributes"] == md5_of_message_attributes
+
+    time.sleep(1.1)
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url, MessageAttributeNames=["foo.*"]
+    )
+    assert set(res["Messages"][0]["MessageAttributes"].keys()) == {
+        "foo.string",
+        "foo.number",
+    }
+
+
+def test_receive_message_without_attribute_names(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.send_message(
+        QueueUrl=queue_url,
+        MessageBody="123",
+        MessageAttributes={"foo": {"DataType": "String", "StringValue": "bar"}},
+    )
+    res = sqs_client.receive_message(QueueUrl=queue_url)
+    assert "MessageAttributes" not in res["Messages"][0]
+
+
+def test_message_body_round_trip(create_random_queue):
+    _, queue_url = create_random_queue()
+    bodies = [
+        "<Body>&amp;</Body>",
+        "]]><![CDATA[",
+        "%s %d %%",
+        "'\"\t\r\n",
+        "Привет, 世界 😀",
+        "�\U0010ffff",
+    ]
+    for body in bodies:
+        sqs_client.send_message(QueueUrl=queue_url, MessageBody=body)
+
+    received = []
+    while len(received) < len(bodies):
+        res = sqs_client.receive_message(QueueUrl=queue_url, MaxNumberOfMessages=10)
+        received += [message["Body"] for message in res.get("Messages", [])]
+    assert sorted(received) == sorted(bodies)
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.send_message(QueueUrl=queue_url, MessageBody="\x00")
+    assert "InvalidMessageContents" in str(exinfo.value)
+
+
+def test_receive_message_system_attributes(create_random_queue):
+    _, queue_url = create_random_queue()
+    sent_at = time.time()
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123")
+
+    res = sqs_client.receive_message(QueueUrl=queue_url, VisibilityTimeout=0)
+    assert "Attributes" not in res["Messages"][0]
+
+    time.sleep(1.1)
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url,
+        AttributeNames=["ApproximateReceiveCount"],
+        MessageSystemAttributeNames=["SentTimestamp"],
+    )
+    attributes = res["Messages"][0]["Attributes"]
+    assert set(attributes.keys()) == {"ApproximateReceiveCount", "SentTimestamp"}
+    assert attributes["ApproximateReceiveCount"] == "2"
+    # Timestamps are in milliseconds
+    assert abs(int(attributes["SentTimestamp"]) - sent_at * 1000) < 1000
+
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.receive_message(QueueUrl=queue_url, AttributeNames=["Foo"])
+    assert "InvalidAttributeName" in str(exinfo.value)
+
+
+def test_receive_message_all_system_attributes():
+    queue_url = create_fifo_queue(content_based_deduplication=True)
+    sqs_client.send_message(QueueUrl=queue_url, MessageBody="123", MessageGroupId="a")
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url, MessageSystemAttributeNames=["All"]
+    )
+    attributes = res["Messages"][0]["Attributes"]
+    assert attributes["SenderId"] == "000000000000"
+    assert attributes["MessageGroupId"] == "a"
+    assert attributes["SequenceNumber"] == "1".zfill(20)
+    assert "MessageDeduplicationId" in attributes
+    assert "ApproximateFirstReceiveTimestamp" in attributes
+    assert "DeadLetterQueueSourceArn" not in attributes
+    sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_aws_trace_header(create_random_queue):
+    _, queue_url = create_random_queue()
+    trace_header = "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1"
+    res = sqs_client.send_message(
+        QueueUrl=queue_url,
+        MessageBody="123",
+        MessageSystemAttributes={
+            "AWSTraceHeader": {"DataType": "String", "StringValue": trace_header}
+        },
+    )
+    assert res["MD5OfMessageSystemAttributes"] == "5f48eef650c1d0207456969c85af2fdd"
+
+    # Trace header of the HTTP request is used when the attribute is not given
+    req = urllib.request.Request(
+        "http://localhost:" + PORT,
+        data=urllib.parse.urlencode(
+            {"Action": "SendMessage", "QueueUrl": queue_url, "MessageBody": "234"}
+        ).encode(),
+        headers={"X-Amzn-Trace-Id": "Root=1-5759e988-00000000000000000000000"},
+    )
+    with urllib.request.urlopen(req) as res:
+        assert res.status == 200
+
+    res = sqs_client.receive_message(
+        QueueUrl=queue_url,
+        MaxNumberOfMessages=10,
+        MessageSystemAttributeNames=["AWSTraceHeader"],
+    )
+    trace_headers = {
+        message["Body"]: message["Attributes"]["AWSTraceHeader"]
+        for message in res["Messages"]
+    }
+    assert trace_headers == {
+        "123": trace_header,
+        "234": "Root=1-5759e988-00000000000000000000000",
+    }
+
+
+def test_send_message_too_large(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.set_queue_attributes(
+        QueueUrl=queue_url, Attributes={"MaximumMessageSize": "1024"}
+    )
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.send_message(
+            QueueUrl=queue_url,
+            MessageBody="x" * 1000,
+            MessageAttributes={"foo": {"DataType": "String", "StringValue": "x" * 100}},
+        )
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+
+def json_request(action, body):
+    req = urllib.request.Request(
+        "http://localhost:" + PORT,
+        data=json.dumps(body).encode(),
+        headers={
+            "Content-Type": "application/x-amz-json-1.0",
+            "X-Amz-Target": "AmazonSQS." + action,
+        },
+    )
+    try:
+        with urllib.request.urlopen(req) as res:
+            return res.status, res.headers, json.loads(res.read())
+    except urllib.error.HTTPError as e:
+        return e.code, e.headers, json.loads(e.read())
+
+
+def test_json_protocol(create_random_queue):
+    _, queue_url = create_random_queue()
+    status, headers, res = json_request(
+        "SendMessage",
+        {
+            "QueueUrl": queue_url,
+            "MessageBody": "123",
+            "MessageAttributes": {"foo": {"DataType": "String", "StringValue": "bar"}},
+        },
+    )
+    assert status == 200
+    assert headers["Content-Type"] == "application/x-amz-json-1.0"
+    assert res["MD5OfMessageBody"] == "202cb962ac59075b964b07152d234b70"
+
+    status, _, res = json_request(
+        "ReceiveMessage",
+        {"QueueUrl": queue_url, "MaxNumberOfMessages": 10, "MessageAttributeNames": ["All"]},
+    )
+    assert status == 200
+    assert res["Messages"][0]["Body"] == "123"
+    assert res["Messages"][0]["MessageAttributes"]["foo"]["StringValue"] == "bar"
+
+
+def test_json_protocol_error():
+    status, headers, res = json_request("GetQueueUrl", {"QueueName": "nonexistent"})
+    assert status == 400
+    assert res["__type"] == "com.amazonaws.sqs#QueueDoesNotExist"
+    assert headers["x-amzn-query-error"] == "AWS.SimpleQueueService.NonExistentQueue;Sender"
+
+
+def test_queue_tags():
+    queue_name = create_queue_name_prefix() + "_tags"
+    queue_url = sqs_client.create_queue(QueueName=queue_name, tags={"env": "dev"})[
+        "QueueUrl"
+    ]
+    try:
+        sqs_client.tag_queue(QueueUrl=queue_url, Tags={"env": "prod", "team": "core"})
+        sqs_client.untag_queue(QueueUrl=queue_url, TagKeys=["team"])
+        res = sqs_client.list_queue_tags(QueueUrl=queue_url)
+        assert res["Tags"] == {"env": "prod"}
+
+        with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+            sqs_client.tag_queue(
+                QueueUrl=queue_url,
+                Tags={"key{}".format(i): "value" for i in range(50)},
+            )
+        assert "InvalidParameterValue" in str(exinfo.value)
+    finally:
+        sqs_client.delete_queue(QueueUrl=queue_url)
+
+
+def test_add_remove_permission(create_random_queue):
+    _, queue_url = create_random_queue()
+    sqs_client.add_permission(
+        QueueUrl=queue_url,
+        Label="producers",
+        AWSAccountIds=["123456789012"],
+        Actions=["SendMessage"],
+    )
+    with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+        sqs_client.add_permission(
+            QueueUrl=queue_url,
+            Label="producers",
+            AWSAccountIds=["123456789012"],
+            Actions=["SendMessage"],
+        )
+    assert "InvalidParameterValue" in str(exinfo.value)
+
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    policy = json.loads(res["Attributes"]["Policy"])
+    assert policy["Statement"][0]["Sid"] == "producers"
+    assert policy["Statement"][0]["Action"] == "SQS:SendMessage"
+
+    sqs_client.remove_permission(QueueUrl=queue_url, Label="producers")
+    res = sqs_client.get_queue_attributes(QueueUrl=queue_url, AttributeNames=["All"])
+    assert "Policy" not in res["Attributes"]
+
+
+def start_server_with_config(server_config, port):
+    fd, config_filename = tempfile.mkstemp(suffix="_go-sqs.json")
+    with os.fdopen(fd, "w") as f:
+        json.dump(server_config, f)
+    proc = subprocess.Popen([build_server(), "-config", config_filename, port])
+    # TODO: get rid of time sync and do a liveness hook instead
+    time.sleep(0.1)
+    return proc
+
+
+def create_client(port, access_key, secret_key="unused", region_name=None):
+    return session.client(
+        service_name="sqs",
+        aws_access_key_id=access_key,
+        aws_secret_access_key=secret_key,
+        endpoint_url="http://localhost:" + port,
+        region_name=region_name,
+        config=config,
+    )
+
+
+def test_policy_enforcement():
+    port = str(int(PORT) + 1)
+    proc = start_server_with_config(
+        {"enforcePolicies": True, "accessKeys": {"other": "111111111111"}}, port
+    )
+    try:
+
+        def client(access_key):
+            return create_client(port, access_key)
+
+        owner, other = client("unused"), client("other")
+        queue_url = owner.create_queue(QueueName="policy_enforcement")["QueueUrl"]
+        with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+            other.send_message(QueueUrl=queue_url, MessageBody="123")
+        assert "AccessDenied" in str(exinfo.value)
+
+        owner.add_permission(
+            QueueUrl=queue_url,
+            Label="producers",
+            AWSAccountIds=["111111111111"],
+            Actions=["SendMessage"],
+        )
+        other.send_message(QueueUrl=queue_url, MessageBody="123")
+        with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+            other.receive_message(QueueUrl=queue_url)
+        assert "AccessDenied" in str(exinfo.value)
+    finally:
+        stop_server(proc)
+
+
+def test_signature_verification():
+    port = str(int(PORT) + 2)
+    fd, credentials_filename = tempfile.mkstemp(suffix="_credentials")
+    with os.fdopen(fd, "w") as f:
+        f.write("[default]\naws_access_key_id = AKID\naws_secret_access_key = SECRET\n")
+    proc = start_server_with_config(
+        {"verifySignatures": True, "credentialsFile": credentials_filename}, port
+    )
+    try:
+        create_client(port, "AKID", "SECRET").list_queues()
+
+        with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+            create_client(port, "AKID", "WRONG").list_queues()
+        assert "SignatureDoesNotMatch" in str(exinfo.value)
+
+        with pytest.raises(botocore.exceptions.ClientError) as exinfo:
+            create_client(port, "UNKNOWN", "SECRET").list_queues()
+        assert "InvalidClientTokenId" in str(exinfo.value)
+    finally:
+        stop_server(proc)
+
+
+def test_configured_endpoint():
+    port = str(int(PORT) + 3)
+    proc = start_server_with_config(
+        {
+            "endpoint": "https://sqs.example.com",
+            "accountId": "123456789012",
+            "region": "eu-west-1",
+        },
+        port,
+    )
+    try:
+        client = create_client(port, "unused")
+        queue_url = client.create_queue(QueueName="configured_endpoint")["QueueUrl"]
+        assert queue_url == "https://sqs.example.com/123456789012/configured_endpoint"
+        res = client.get_queue_attributes(
+            QueueUrl=queue_url, AttributeNames=["QueueArn"]
+        )
+        assert (
+            res["Attributes"]["QueueArn"]
+            == "arn:aws:sqs:eu-west-1:123456789012:configured_endpoint"
+        )
+    finally:
+        stop_server(proc)
+
+
+def test_account_and_region_namespaces():
+    port = str(int(PORT) + 4)
+    proc = start_server_with_config({"accessKeys": {"other": "111111111111"}}, port)
+    try:
+        owner = create_client(port, "unused", region_name="us-east-1")
+        other = create_client(port, "other", region_name="us-east-1")
+        owner_eu = create_client(port, "unused", region_name="eu-west-1")
+
+        owner_url = owner.create_queue(QueueName="namespaced")["QueueUrl"]
+        other_url = other.create_queue(QueueName="namespaced")["QueueUrl"]
+        owner_eu.create_queue(QueueName="namespaced")
+        assert owner_url.endswith("/000000000000/namespaced")
+        assert other_url.endswith("/111111111111/namespaced")
+
+        owner.send_message(QueueUrl=owner_url, MessageBody="123")
+        res = owner.get_queue_attributes(
+            QueueUrl=owner_url, AttributeNames=["ApproximateNumberOfMessages"]
+        )
+        assert res["Attributes"]["ApproximateNumberOfMessages"] == "1"
+        for client, url in [(other, other_url), (owner_eu, owner_url)]:
+            res = client.get_queue_attributes(
+                QueueUrl=url, AttributeNames=["ApproximateNumberOfMessages"]
+            )
+            assert res["Attributes"]["ApproximateNumberOfMessages"] == "0"
+
+        assert owner.list_queues()["QueueUrls"] == [owner_url]
+        res = owner.get_queue_url(
+            QueueName="namespaced", QueueOwnerAWSAccountId="111111111111"
+        )
+        assert res["QueueUrl"] == other_url
+    finally:
+        stop_server(proc)